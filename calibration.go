@@ -0,0 +1,593 @@
+package vl53l0x
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	i2c "github.com/d2r2/go-i2c"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// CalibrationData keeps sensor calibration results, which could be
+// persisted and restored instead of repeated calibration.
+// Can be exported either to JSON, or compact binary form
+// (see MarshalBinary).
+type CalibrationData struct {
+	// Reference SPAD (single photon avalanche diode) count.
+	RefSpadCount byte `json:"refSpadCount"`
+	// Reference SPAD type is aperture.
+	RefSpadTypeIsAperture bool `json:"refSpadTypeIsAperture"`
+	// Part to part range offset in micrometers.
+	OffsetMicroMeter int32 `json:"offsetMicroMeter"`
+	// Crosstalk compensation peak rate in MCPS.
+	XTalkCompensationRateMcps float32 `json:"xTalkCompensationRateMcps"`
+	// VHV (very high voltage) calibration settings.
+	VhvSettings byte `json:"vhvSettings"`
+	// Phase calibration result.
+	PhaseCal byte `json:"phaseCal"`
+}
+
+// Version of binary calibration data layout.
+const calibrationDataVersion = 1
+
+// Size of binary calibration data layout in bytes.
+const calibrationDataSize = 12
+
+// MarshalBinary implement encoding.BinaryMarshaler interface.
+// Encode calibration data to 12 bytes in little-endian layout:
+//
+//	[0]     layout version
+//	[1]     reference SPAD count
+//	[2]     flags (bit 0: reference SPAD type is aperture)
+//	[3:7]   offset in micrometers (int32)
+//	[7:9]   crosstalk compensation rate in MCPS (Q3.13 fixed point)
+//	[9]     VHV settings
+//	[10]    phase calibration
+//	[11]    CRC-8 of bytes [0:11]
+func (v *CalibrationData) MarshalBinary() ([]byte, error) {
+	if v.XTalkCompensationRateMcps < 0 || v.XTalkCompensationRateMcps >= 8 {
+		return nil, errors.New("crosstalk compensation rate is out of range")
+	}
+	buf := make([]byte, calibrationDataSize)
+	buf[0] = calibrationDataVersion
+	buf[1] = v.RefSpadCount
+	if v.RefSpadTypeIsAperture {
+		buf[2] |= 0x01
+	}
+	binary.LittleEndian.PutUint32(buf[3:7], uint32(v.OffsetMicroMeter))
+	// Q3.13 fixed point format (3 integer bits, 13 fractional bits)
+	binary.LittleEndian.PutUint16(buf[7:9], uint16(v.XTalkCompensationRateMcps*(1<<13)))
+	buf[9] = v.VhvSettings
+	buf[10] = v.PhaseCal
+	buf[11] = crc8(buf[:11])
+	return buf, nil
+}
+
+// UnmarshalBinary implement encoding.BinaryUnmarshaler interface.
+// Decode calibration data produced by MarshalBinary. Return error
+// if data is corrupted or has unsupported layout version.
+func (v *CalibrationData) UnmarshalBinary(data []byte) error {
+	if len(data) < calibrationDataSize {
+		return errors.New(spew.Sprintf("calibration data is too short: %d bytes", len(data)))
+	}
+	if data[0] != calibrationDataVersion {
+		return errors.New(spew.Sprintf("unsupported calibration data version %d", data[0]))
+	}
+	if crc8(data[:11]) != data[11] {
+		return errors.New("calibration data checksum mismatch")
+	}
+	v.RefSpadCount = data[1]
+	v.RefSpadTypeIsAperture = data[2]&0x01 != 0
+	v.OffsetMicroMeter = int32(binary.LittleEndian.Uint32(data[3:7]))
+	v.XTalkCompensationRateMcps = float32(binary.LittleEndian.Uint16(data[7:9])) / (1 << 13)
+	v.VhvSettings = data[9]
+	v.PhaseCal = data[10]
+	return nil
+}
+
+// Calculate CRC-8 checksum (polynomial 0x07).
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// CalibrationConfig define how many measurements are taken
+// by CalibrateOffset and CalibrateCrosstalk.
+type CalibrationConfig struct {
+	// Number of valid measurements to collect.
+	Samples int
+	// Maximum calibration duration; calibration stops when either Samples
+	// valid measurements are collected, or MaxDuration expires. Zero value
+	// means no time limit, but total number of measurements (including
+	// invalid ones) is limited to twice Samples then.
+	MaxDuration time.Duration
+	// Minimum number of valid measurements, which is enough to complete
+	// calibration, when MaxDuration expires. Zero value means half of Samples.
+	MinSamples int
+	// Optional calibration progress reporter.
+	Progress ProgressFunc
+}
+
+// ProgressFunc is called by long running operations at each step,
+// with step name and total progress in percents (0..100).
+type ProgressFunc func(step string, pct float64)
+
+// Report calibration progress, if reporter is specified.
+func (v *CalibrationConfig) report(step string, pct float64) {
+	if v.Progress != nil {
+		v.Progress(step, pct)
+	}
+}
+
+// Averaged result of calibration measurements.
+type calibrationMeasurement struct {
+	rangeMm    float32
+	signalMcps float32
+	spadCount  float32
+	samples    int
+}
+
+// CalibrateOffset perform part to part offset calibration, based on
+// VL53L0X_PerformOffsetCalibration(). Target (white, 88% reflectance
+// recommended) should be placed at targetMm distance. Measured offset
+// is applied to the sensor, and returned along with number of valid
+// measurements used. Continuous mode should be stopped.
+func (v *Vl53l0x) CalibrateOffset(i2c *i2c.I2C, targetMm uint16,
+	cfg CalibrationConfig) (offsetMicroMeter int32, samples int, err error) {
+
+	v.debug("Start offset calibration")
+
+	// measure without offset applied
+	cfg.report("reset offset", 0)
+	err = v.SetOffsetCalibration(i2c, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	m, err := v.measureForCalibration(i2c, cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	offsetMicroMeter = (int32(targetMm) - int32(m.rangeMm+0.5)) * 1000
+	cfg.report("apply offset", 95)
+	err = v.SetOffsetCalibration(i2c, offsetMicroMeter)
+	if err != nil {
+		return 0, 0, err
+	}
+	cfg.report("done", 100)
+	v.debugf("Offset calibration done: %d um from %d samples", offsetMicroMeter, m.samples)
+	return offsetMicroMeter, m.samples, nil
+}
+
+// CalibrateCrosstalk perform crosstalk calibration, based on
+// VL53L0X_PerformXTalkCalibration(). Target (grey, 17% reflectance
+// recommended) should be placed at targetMm distance, behind cover glass.
+// Measured crosstalk compensation rate is applied to the sensor, and
+// returned along with number of valid measurements used. Offset should
+// be calibrated first. Continuous mode should be stopped.
+func (v *Vl53l0x) CalibrateCrosstalk(i2c *i2c.I2C, targetMm uint16,
+	cfg CalibrationConfig) (rateMcps float32, samples int, err error) {
+
+	v.debug("Start crosstalk calibration")
+
+	if targetMm == 0 {
+		return 0, 0, errors.New("crosstalk calibration distance is zero")
+	}
+	// measure without compensation applied
+	cfg.report("reset crosstalk compensation", 0)
+	err = v.SetCrosstalkCompensation(i2c, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	m, err := v.measureForCalibration(i2c, cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if m.spadCount != 0 && m.rangeMm < float32(targetMm) {
+		// crosstalk part of return signal rate per SPAD
+		rateMcps = m.signalMcps / m.spadCount * (1 - m.rangeMm/float32(targetMm))
+	}
+	cfg.report("apply crosstalk compensation", 95)
+	err = v.SetCrosstalkCompensation(i2c, rateMcps)
+	if err != nil {
+		return 0, 0, err
+	}
+	cfg.report("done", 100)
+	v.debugf("Crosstalk calibration done: %v MCPS from %d samples", rateMcps, m.samples)
+	return rateMcps, m.samples, nil
+}
+
+// Number of valid measurements taken by FactoryCalibrate
+// for each calibration step, same as ST API examples use.
+const factoryCalibrationSamples = 50
+
+// FactoryCalibrate run complete calibration flow in order recommended by ST:
+// reference SPAD info, reference (VHV and phase) calibration, offset calibration
+// and, if hasCoverGlass, crosstalk calibration. Target should be placed
+// at knownDistanceMm distance (see CalibrateOffset and CalibrateCrosstalk
+// for target recommendations). All results are applied to the sensor,
+// and returned to be persisted. Sensor should be initialized, and
+// continuous mode should be stopped.
+//
+// Reference SPAD count and type are re-read from sensor NVM, where they are
+// stored by ST factory calibration, rather than obtained by reference SPAD
+// management (see Init).
+func (v *Vl53l0x) FactoryCalibrate(i2c *i2c.I2C, knownDistanceMm uint16,
+	hasCoverGlass bool) (*CalibrationData, error) {
+
+	v.debug("Start factory calibration")
+
+	if !v.initialized {
+		return nil, errors.New("sensor is not initialized")
+	}
+	data := &CalibrationData{}
+
+	v.ForceSpadInfoRefresh()
+	si, err := v.getSpadInfo(i2c)
+	if err != nil {
+		return nil, err
+	}
+	data.RefSpadCount = si.Count
+	data.RefSpadTypeIsAperture = si.TypeIsAperture
+
+	err = v.PerformRefCalibration(i2c)
+	if err != nil {
+		return nil, err
+	}
+	data.VhvSettings, data.PhaseCal, err = v.GetRefCalibration(i2c)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := CalibrationConfig{Samples: factoryCalibrationSamples}
+	data.OffsetMicroMeter, _, err = v.CalibrateOffset(i2c, knownDistanceMm, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if hasCoverGlass {
+		data.XTalkCompensationRateMcps, _, err = v.CalibrateCrosstalk(i2c, knownDistanceMm, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v.debugf("Factory calibration done: %#v", data)
+
+	return data, nil
+}
+
+// Registers keeping reference calibration results.
+const (
+	refCalibrationVhvSettings = 0xCB
+	refCalibrationPhaseCal    = 0xEE
+)
+
+// GetRefCalibration read VHV (very high voltage) settings and phase
+// calibration results of last reference calibration (see PerformRefCalibration),
+// which could be persisted and restored by SetRefCalibration.
+// Based on VL53L0X_GetRefCalibration().
+func (v *Vl53l0x) GetRefCalibration(i2c *i2c.I2C) (vhvSettings, phaseCal byte, err error) {
+	err = v.withRefCalibrationAccess(i2c, func() error {
+		var err error
+		vhvSettings, err = v.readRegU8(i2c, refCalibrationVhvSettings)
+		if err != nil {
+			return err
+		}
+		phaseCal, err = v.readRegU8(i2c, refCalibrationPhaseCal)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return vhvSettings, phaseCal & 0xEF, nil
+}
+
+// SetRefCalibration apply VHV settings and phase calibration results
+// obtained by GetRefCalibration before, instead of reference calibration.
+// Based on VL53L0X_SetRefCalibration().
+func (v *Vl53l0x) SetRefCalibration(i2c *i2c.I2C, vhvSettings, phaseCal byte) error {
+	return v.withRefCalibrationAccess(i2c, func() error {
+		err := v.writeRegU8(i2c, refCalibrationVhvSettings, vhvSettings)
+		if err != nil {
+			return err
+		}
+		// keep bit 7 of register intact
+		u8, err := v.readRegU8(i2c, refCalibrationPhaseCal)
+		if err != nil {
+			return err
+		}
+		return v.writeRegU8(i2c, refCalibrationPhaseCal, u8&0x80|phaseCal)
+	})
+}
+
+// Make reference calibration registers accessible, call fn,
+// then restore access state. Based on VL53L0X_ref_calibration_io().
+func (v *Vl53l0x) withRefCalibrationAccess(i2c *i2c.I2C, fn func() error) (err error) {
+	err = v.writeRegValues(i2c, []RegBytePair{
+		{Reg: 0xFF, Value: 0x01},
+		{Reg: 0x00, Value: 0x00},
+		{Reg: 0xFF, Value: 0x00},
+	}...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err2 := v.writeRegValues(i2c, []RegBytePair{
+			{Reg: 0xFF, Value: 0x01},
+			{Reg: 0x00, Value: 0x01},
+			{Reg: 0xFF, Value: 0x00},
+		}...)
+		if err == nil {
+			err = err2
+		}
+	}()
+	return fn()
+}
+
+// RefSignalRateTargetMcps is a reference signal rate in MCPS, which
+// reference SPAD management aims to achieve (0x0A00 in Q9.7 format,
+// default of VL53L0X API).
+const RefSignalRateTargetMcps = 20
+
+// GetRefSignalRate read reference signal rate in MCPS of the most recent
+// measurement from the sensor. Reference rate far from RefSignalRateTargetMcps
+// indicates calibration problem, like dirty cover window or bad module.
+// Should be called after measurement (or reference calibration) is completed.
+// Based on VL53L0X_perform_ref_signal_measurement().
+func (v *Vl53l0x) GetRefSignalRate(i2c *i2c.I2C) (rateMcps float32, err error) {
+	// register is located on page 1
+	err = v.writeRegU8(i2c, 0xFF, 0x01)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		err2 := v.writeRegU8(i2c, 0xFF, 0x00)
+		if err == nil {
+			err = err2
+		}
+	}()
+	u16, err := v.readRegU16(i2c, RESULT_PEAK_SIGNAL_RATE_REF)
+	if err != nil {
+		return 0, err
+	}
+	return FixedToMCPS(u16), nil
+}
+
+// SetOffsetCalibration apply part to part range offset in micrometers,
+// which is added to measured distance by the sensor. Offset is stored
+// in 1/4 mm units, and limited to -512..511 mm range.
+// Based on VL53L0X_SetOffsetCalibrationDataMicroMeter().
+func (v *Vl53l0x) SetOffsetCalibration(i2c *i2c.I2C, offsetMicroMeter int32) error {
+	const minOffsetMicroMeter = -512000
+	const maxOffsetMicroMeter = 511000
+	if offsetMicroMeter < minOffsetMicroMeter {
+		offsetMicroMeter = minOffsetMicroMeter
+	} else if offsetMicroMeter > maxOffsetMicroMeter {
+		offsetMicroMeter = maxOffsetMicroMeter
+	}
+	// 12-bit two's complement value in 10.2 fixed point format
+	u16 := uint16(offsetMicroMeter/250) & 0x0FFF
+	return v.writeRegU16(i2c, ALGO_PART_TO_PART_RANGE_OFFSET_MM, u16)
+}
+
+// GetOffsetCalibration returns part to part range offset in micrometers
+// (see SetOffsetCalibration), read from the sensor.
+// Based on VL53L0X_GetOffsetCalibrationDataMicroMeter().
+func (v *Vl53l0x) GetOffsetCalibration(i2c *i2c.I2C) (int32, error) {
+	u16, err := v.readRegU16(i2c, ALGO_PART_TO_PART_RANGE_OFFSET_MM)
+	if err != nil {
+		return 0, err
+	}
+	// sign extend 12-bit two's complement value in 10.2 fixed point format
+	offset := int32(u16&0x0FFF) << 20 >> 20
+	return offset * 250, nil
+}
+
+// SetCrosstalkCompensation apply crosstalk compensation rate in MCPS
+// (per SPAD), which is subtracted from return signal by the sensor.
+// Distance is corrected for crosstalk in software by ReadRangeCorrected.
+// Zero value disables compensation.
+// Based on VL53L0X_SetXTalkCompensationRateMegaCps().
+func (v *Vl53l0x) SetCrosstalkCompensation(i2c *i2c.I2C, rateMcps float32) error {
+	if rateMcps < 0 || rateMcps >= 8 {
+		return errors.New("crosstalk compensation rate is out of range")
+	}
+	// Q3.13 fixed point format (3 integer bits, 13 fractional bits)
+	err := v.writeRegU16(i2c, CROSSTALK_COMPENSATION_PEAK_RATE_MCPS,
+		uint16(rateMcps*(1<<13)))
+	if err != nil {
+		return err
+	}
+	v.xTalkCompensationRateMcps = rateMcps
+	return nil
+}
+
+// Default linearity corrective gain (no correction).
+const defaultLinearityCorrectiveGain = 1000
+
+// SetLinearityCorrectiveGain set linearity corrective gain in 1/1000 units
+// (1..1000), applied by ReadRangeCorrected to measured distance.
+// Default value 1000 means no correction. Unlike offset compensation,
+// gain isn't applied by the sensor, so other measurement methods
+// return uncorrected distance.
+// Based on VL53L0X_SetLinearityCorrectiveGain().
+func (v *Vl53l0x) SetLinearityCorrectiveGain(gain uint16) error {
+	if gain == 0 || gain > defaultLinearityCorrectiveGain {
+		return errors.New(spew.Sprintf("linearity corrective gain %d is out of range 1..%d",
+			gain, defaultLinearityCorrectiveGain))
+	}
+	v.linearityCorrectiveGain = gain
+	return nil
+}
+
+// ReadRangeCorrected performs range measurement (single-shot one, unless
+// continuous mode is active) and returns distance in millimeters with all
+// calibrations applied. Offset (see SetOffsetCalibration) is applied
+// by the sensor itself, so it's already accounted in measured distance.
+// Crosstalk compensation (see SetCrosstalkCompensation) is applied
+// in software, like VL53L0X_GetRangingMeasurementData() does: distance
+// is scaled by ratio of return signal rate to return signal rate without
+// crosstalk, and reported as 8888 mm, when crosstalk exceeds return signal.
+// Linearity corrective gain (see SetLinearityCorrectiveGain) is applied
+// on top of that. Out of range distance is returned unchanged.
+func (v *Vl53l0x) ReadRangeCorrected(i2c *i2c.I2C) (float64, error) {
+
+	v.debug("Read range corrected")
+
+	var buf [resultBlockSize]byte
+	var data RangeData
+	err := v.ReadRangeDataInto(i2c, buf[:], &data)
+	if err != nil {
+		return 0, err
+	}
+	rng := float64(data.RangeMm) + v.crosstalkCorrection(data.RangeMm, buf[:])
+	return rng + v.linearityCorrection(rng), nil
+}
+
+// CorrectedRangeData keeps measurement result along with calibration
+// corrections applied to it, to verify that calibration works as expected.
+type CorrectedRangeData struct {
+	// Measurement result; RangeMm is a distance reported by the sensor,
+	// with offset compensation applied by the sensor.
+	RangeData
+	// Distance without offset correction in millimeters.
+	RawRangeMm float64
+	// Part to part offset in millimeters added by the sensor.
+	OffsetMm float64
+	// Crosstalk compensation rate in MCPS per SPAD,
+	// as set by SetCrosstalkCompensation.
+	XTalkCompensationRateMcps float32
+	// Crosstalk correction in millimeters added
+	// to distance reported by the sensor.
+	XTalkDeltaMm float64
+	// Linearity corrective gain correction in millimeters
+	// added to crosstalk corrected distance.
+	LinearityDeltaMm float64
+	// Distance with all calibrations applied (see ReadRangeCorrected).
+	CorrectedRangeMm float64
+}
+
+// ReadRangeCorrectedData performs range measurement like ReadRangeCorrected
+// does, and returns both raw and corrected distance, along with corrections
+// applied. Out of range distance isn't corrected.
+func (v *Vl53l0x) ReadRangeCorrectedData(i2c *i2c.I2C) (*CorrectedRangeData, error) {
+
+	v.debug("Read range corrected data")
+
+	offsetMicroMeter, err := v.GetOffsetCalibration(i2c)
+	if err != nil {
+		return nil, err
+	}
+	var buf [resultBlockSize]byte
+	data := &CorrectedRangeData{}
+	err = v.ReadRangeDataInto(i2c, buf[:], &data.RangeData)
+	if err != nil {
+		return nil, err
+	}
+	data.XTalkCompensationRateMcps = v.xTalkCompensationRateMcps
+	data.RawRangeMm = float64(data.RangeMm)
+	data.CorrectedRangeMm = float64(data.RangeMm)
+	if data.RangeMm < outOfRange {
+		data.OffsetMm = float64(offsetMicroMeter) / 1000
+		data.RawRangeMm -= data.OffsetMm
+		data.XTalkDeltaMm = v.crosstalkCorrection(data.RangeMm, buf[:])
+		data.CorrectedRangeMm += data.XTalkDeltaMm
+		data.LinearityDeltaMm = v.linearityCorrection(data.CorrectedRangeMm)
+		data.CorrectedRangeMm += data.LinearityDeltaMm
+	}
+	return data, nil
+}
+
+// Distance reported by crosstalk correction, when crosstalk
+// exceeds return signal, same as in ST API.
+const xTalkRangeOverflowMm = 8888
+
+// Calculate crosstalk correction in millimeters to be added to distance rng
+// of measurement result kept in result block buf. Out of range distance
+// isn't corrected. Based on VL53L0X_GetRangingMeasurementData().
+func (v *Vl53l0x) crosstalkCorrection(rng uint16, buf []byte) float64 {
+	if rng >= outOfRange || v.xTalkCompensationRateMcps == 0 {
+		return 0
+	}
+	// signal rate in Q9.7 fixed point format,
+	// effective SPAD return count in 8.8 fixed point format
+	signalMcps := float64(FixedToMCPS(uint16(buf[6])<<8 | uint16(buf[7])))
+	spadCount := float64(uint16(buf[2])<<8|uint16(buf[3])) / (1 << 8)
+	signalNoXTalkMcps := signalMcps - float64(v.xTalkCompensationRateMcps)*spadCount
+	if signalNoXTalkMcps <= 0 {
+		return xTalkRangeOverflowMm - float64(rng)
+	}
+	return float64(rng)*signalMcps/signalNoXTalkMcps - float64(rng)
+}
+
+// Calculate linearity corrective gain correction in millimeters
+// to be added to distance rng. Out of range distance isn't corrected.
+func (v *Vl53l0x) linearityCorrection(rng float64) float64 {
+	if rng >= outOfRange || v.linearityCorrectiveGain == 0 {
+		return 0
+	}
+	gain := float64(v.linearityCorrectiveGain) / defaultLinearityCorrectiveGain
+	return rng * (gain - 1)
+}
+
+// Take single-shot measurements according to cfg,
+// and average valid ones.
+func (v *Vl53l0x) measureForCalibration(i2c *i2c.I2C,
+	cfg CalibrationConfig) (*calibrationMeasurement, error) {
+
+	if v.continuous {
+		return nil, errors.New("calibration requires continuous mode to be stopped")
+	}
+	if cfg.Samples <= 0 {
+		return nil, errors.New("number of calibration samples should be positive")
+	}
+	minSamples := cfg.MinSamples
+	if minSamples <= 0 {
+		minSamples = (cfg.Samples + 1) / 2
+	}
+	maxAttempts := 0
+	if cfg.MaxDuration == 0 {
+		maxAttempts = cfg.Samples * 2
+	}
+
+	cfg.report("measure", 5)
+	m := &calibrationMeasurement{}
+	var rangeSum, signalSum, spadSum float32
+	st := time.Now()
+	for attempt := 1; m.samples < cfg.Samples; attempt++ {
+		data, err := v.measureRangingData(i2c)
+		if err != nil {
+			return nil, err
+		}
+		if data.RangeStatus == RangeStatusValid {
+			m.samples++
+			rangeSum += float32(data.RangeMilliMeter)
+			signalSum += data.SignalRateRtnMegaCps
+			spadSum += data.EffectiveSpadRtnCount
+			// measurements take progress from 5 to 95 percents
+			cfg.report("measure", 5+90*float64(m.samples)/float64(cfg.Samples))
+		}
+		if (maxAttempts > 0 && attempt >= maxAttempts) ||
+			(cfg.MaxDuration > 0 && time.Since(st) >= cfg.MaxDuration) {
+			break
+		}
+	}
+	if m.samples < minSamples {
+		return nil, errors.New(spew.Sprintf("not enough valid calibration samples: "+
+			"%d collected, %d required", m.samples, minSamples))
+	}
+	m.rangeMm = rangeSum / float32(m.samples)
+	m.signalMcps = signalSum / float32(m.samples)
+	m.spadCount = spadSum / float32(m.samples)
+	return m, nil
+}
@@ -0,0 +1,141 @@
+package vl53l0x
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// BusDiagnostics keeps I2C-bus health statistics collected by DiagnoseBus.
+type BusDiagnostics struct {
+	// Number of reads performed.
+	Reads int
+	// Number of failed reads.
+	Failures int
+	// Number of successful reads, which returned unexpected value
+	// (data corrupted on the bus).
+	Corrupted int
+	// Ratio of failed and corrupted reads to all reads (0..1).
+	FailureRate float64
+	// Latency of successful reads.
+	MinLatency  time.Duration
+	MaxLatency  time.Duration
+	MeanLatency time.Duration
+	// Latency percentiles of successful reads.
+	P50Latency time.Duration
+	P90Latency time.Duration
+	P99Latency time.Duration
+}
+
+// DiagnoseBus perform reads consecutive timed reads of sensor model
+// identifier, which value is known in advance, and report latency
+// statistics and failure rate. Helps to spot bus timing issues at bring-up,
+// like host controller clock stretching timeout shorter than sensor
+// needs: such issues show up as sporadic failures, or latency outliers.
+// Error is returned only if bus or device is gone.
+func (v *Vl53l0x) DiagnoseBus(i2c *i2c.I2C, reads int) (*BusDiagnostics, error) {
+
+	v.debugf("Diagnose bus with %d reads", reads)
+
+	if reads <= 0 {
+		return nil, errors.New("reads count should be positive")
+	}
+	diag := &BusDiagnostics{Reads: reads}
+	latencies := make([]time.Duration, 0, reads)
+	for i := 0; i < reads; i++ {
+		st := time.Now()
+		id, err := v.readRegU8(i2c, IDENTIFICATION_MODEL_ID)
+		latency := time.Since(st)
+		if err != nil {
+			if isFatalBusError(err) || errors.Is(err, ErrClosed) {
+				return nil, err
+			}
+			diag.Failures++
+			continue
+		}
+		if id != modelID {
+			diag.Corrupted++
+			continue
+		}
+		latencies = append(latencies, latency)
+	}
+	diag.FailureRate = float64(diag.Failures+diag.Corrupted) / float64(reads)
+
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool {
+			return latencies[i] < latencies[j]
+		})
+		var sum time.Duration
+		for _, latency := range latencies {
+			sum += latency
+		}
+		diag.MinLatency = latencies[0]
+		diag.MaxLatency = latencies[len(latencies)-1]
+		diag.MeanLatency = sum / time.Duration(len(latencies))
+		diag.P50Latency = percentile(latencies, 50)
+		diag.P90Latency = percentile(latencies, 90)
+		diag.P99Latency = percentile(latencies, 99)
+	}
+
+	v.debugf("Bus diagnostics = %#v", diag)
+
+	return diag, nil
+}
+
+// Returns pct percentile of sorted values (nearest-rank method).
+func percentile(sorted []time.Duration, pct int) time.Duration {
+	rank := (pct*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// HealthReport keeps sensor state collected by SelfTest.
+type HealthReport struct {
+	// Measured distance in millimeters.
+	RangeMm uint16
+	// Device range status (see DetailedRangeData.DeviceRangeStatus).
+	DeviceRangeStatus byte
+	// Return signal and ambient rates in MCPS.
+	SignalRateMcps  float32
+	AmbientRateMcps float32
+	// Reference signal rate in MCPS (see GetRefSignalRate), which
+	// is expected to be close to RefSignalRateTargetMcps.
+	RefSignalRateMcps float32
+}
+
+// SelfTest verify sensor model identifier, take one measurement
+// (see ReadRangeDetailed) and report measurement result together
+// with reference signal rate, which helps to estimate calibration
+// quality. Error is returned, if sensor doesn't respond, isn't VL53L0X,
+// or measurement fails.
+func (v *Vl53l0x) SelfTest(i2c *i2c.I2C) (*HealthReport, error) {
+
+	v.debug("Self test")
+
+	err := v.CheckModelID(i2c)
+	if err != nil {
+		return nil, err
+	}
+	data, err := v.ReadRangeDetailed(i2c)
+	if err != nil {
+		return nil, err
+	}
+	report := &HealthReport{
+		RangeMm:           data.RangeMm,
+		DeviceRangeStatus: data.DeviceRangeStatus,
+		SignalRateMcps:    data.SignalRateMcps,
+		AmbientRateMcps:   data.AmbientRateMcps,
+	}
+	report.RefSignalRateMcps, err = v.GetRefSignalRate(i2c)
+	if err != nil {
+		return nil, err
+	}
+
+	v.debugf("Health report = %#v", report)
+
+	return report, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	i2c "github.com/d2r2/go-i2c"
+	logger "github.com/d2r2/go-logger"
+	vl53l0x "github.com/d2r2/go-vl53l0x"
+)
+
+var lg = logger.NewPackageLogger("main",
+	logger.InfoLevel,
+)
+
+// Minimal example: take single-shot range measurement from the sensor
+// at default address, without changing sensor address.
+func main() {
+	defer logger.FinalizeLogger()
+	// Create new connection to i2c-bus on 0 line with default sensor address 0x29.
+	// Use i2cdetect utility to find device address over the i2c-bus
+	i2c, err := i2c.NewI2C(vl53l0x.DefaultAddress, 0)
+	if err != nil {
+		lg.Fatal(err)
+	}
+	defer i2c.Close()
+
+	logger.ChangePackageLogLevel("i2c", logger.InfoLevel)
+	logger.ChangePackageLogLevel("vl53l0x", logger.InfoLevel)
+
+	sensor := vl53l0x.NewVl53l0x()
+	// It's highly recommended to reset sensor before initialization.
+	err = sensor.Reset(i2c)
+	if err != nil {
+		lg.Fatalf("Error reseting sensor: %s", err)
+	}
+	err = sensor.EnsureInitialized(i2c)
+	if err != nil {
+		lg.Fatalf("Failed to initialize sensor: %s", err)
+	}
+	err = sensor.Config(i2c, vl53l0x.RegularRange, vl53l0x.RegularAccuracy)
+	if err != nil {
+		lg.Fatalf("Failed to configure sensor: %s", err)
+	}
+	rng, err := sensor.ReadRangeSingleMillimeters(i2c)
+	if err != nil {
+		lg.Fatalf("Failed to measure range: %s", err)
+	}
+	lg.Infof("Measured range = %v mm", rng)
+}
@@ -0,0 +1,24 @@
+package vl53l0x
+
+import "strconv"
+
+// FormatDistance returns distance in human readable form for display:
+// millimeters below 1 meter (like "45mm"), meters with centimeter precision
+// otherwise (like "1.23m"), or "out of range" for MaxRangeMm and above.
+func FormatDistance(mm uint16) string {
+	if mm >= MaxRangeMm {
+		return "out of range"
+	}
+	var buf [8]byte
+	var b []byte
+	if mm < 1000 {
+		b = strconv.AppendUint(buf[:0], uint64(mm), 10)
+		b = append(b, "mm"...)
+		return string(b)
+	}
+	// round to centimeters
+	cm := (uint64(mm) + 5) / 10
+	b = strconv.AppendUint(buf[:0], cm/100, 10)
+	b = append(b, '.', byte('0'+cm%100/10), byte('0'+cm%10), 'm')
+	return string(b)
+}
@@ -0,0 +1,462 @@
+package vl53l0x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	i2c "github.com/d2r2/go-i2c"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// DefaultAddress is a sensor I2C-bus address after power on or hardware reset.
+const DefaultAddress = 0x29
+
+// Sensor model identifier, read from IDENTIFICATION_MODEL_ID register.
+const modelID = 0xEE
+
+// Time to wait for sensor boot after XSHUT pin release.
+const bootTimeout = time.Millisecond * 500
+
+// GroupMember keeps sensor included in SensorGroup.
+type GroupMember struct {
+	// Sensor instance.
+	Sensor *Vl53l0x
+	// Connection to the sensor; available once address is assigned.
+	I2C *i2c.I2C
+	// Address assigned to the sensor; zero, if not assigned yet.
+	Address byte
+	// Drive sensor XSHUT pin: low level (false) keeps sensor
+	// in hardware standby, high level (true) let it boot.
+	SetXShut func(high bool) error
+}
+
+// SensorGroup manage multiple sensors connected to the same I2C-bus.
+// Since each sensor starts with the same DefaultAddress, sensors should
+// be brought out of hardware standby (via XSHUT pin) one by one,
+// to get unique address.
+type SensorGroup struct {
+	// serialize group operations, since sensors share the same bus
+	mu      sync.Mutex
+	bus     int
+	members []*GroupMember
+	// first address assigned by AutoAssignAddresses; zero, if not assigned yet
+	base byte
+}
+
+// NewSensorGroup creates empty sensor group on I2C-bus number bus.
+func NewSensorGroup(bus int) *SensorGroup {
+	v := &SensorGroup{bus: bus}
+	return v
+}
+
+// Add include new sensor to the group. Label is used to distinguish
+// sensor log output (see Vl53l0x.SetLabel); setXShut should drive
+// sensor XSHUT pin.
+func (v *SensorGroup) Add(label string, setXShut func(high bool) error) *GroupMember {
+	sensor := NewVl53l0x()
+	sensor.SetLabel(label)
+	member := &GroupMember{Sensor: sensor, SetXShut: setXShut}
+	v.members = append(v.members, member)
+	return member
+}
+
+// Members returns sensors included in the group, in order of addition.
+func (v *SensorGroup) Members() []*GroupMember {
+	return v.members
+}
+
+// AutoAssignAddresses put all sensors to hardware standby, then bring them
+// up one by one, assigning sequential addresses starting from base.
+// Each address is checked to be free before assignment, and verified
+// by reading sensor model identifier afterwards.
+func (v *SensorGroup) AutoAssignAddresses(base byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.autoAssignAddresses(base)
+}
+
+// Assign sequential addresses starting from base (see AutoAssignAddresses).
+func (v *SensorGroup) autoAssignAddresses(base byte) error {
+	if len(v.members) == 0 {
+		return nil
+	}
+	last := int(base) + len(v.members) - 1
+	if base < 0x08 || last > 0x77 {
+		return errors.New(spew.Sprintf("addresses 0x%x..0x%x are out of valid range 0x08..0x77",
+			base, last))
+	}
+	if DefaultAddress >= int(base) && DefaultAddress <= last {
+		return errors.New(spew.Sprintf("addresses 0x%x..0x%x overlap default address 0x%x",
+			base, last, DefaultAddress))
+	}
+
+	v.debug("Put group sensors to hardware standby")
+	err := v.shutdownAll()
+	if err != nil {
+		return err
+	}
+
+	for i, member := range v.members {
+		addr := base + byte(i)
+		if v.probe(addr) {
+			return errors.New(spew.Sprintf("address 0x%x is occupied by another device", addr))
+		}
+		err = v.bringUp(member, addr)
+		if err != nil {
+			return err
+		}
+	}
+	v.base = base
+	return nil
+}
+
+// RecoverGroup check that all sensors still respond at assigned addresses,
+// and if not (for instance, power loss reverted them to DefaultAddress,
+// where they collide), repeat address assignment done by AutoAssignAddresses.
+// Sensor settings are lost on power loss, so recovered sensors are marked
+// as not initialized and should be initialized again (see EnsureInitialized).
+func (v *SensorGroup) RecoverGroup() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.base == 0 {
+		return errors.New("addresses are not assigned yet")
+	}
+	if v.isHealthy() {
+		return nil
+	}
+
+	v.debug("Group sensors don't respond at assigned addresses, recover them")
+
+	return v.autoAssignAddresses(v.base)
+}
+
+// ReadAll take one measurement from each sensor of the group, one by one,
+// and returns distances in millimeters and errors in the order of sensors
+// addition. Continuous measurement result is taken from sensor, where
+// continuous mode is active, otherwise single-shot measurement is performed.
+// Safe for concurrent use with other group methods.
+func (v *SensorGroup) ReadAll() ([]uint16, []error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	ranges := make([]uint16, len(v.members))
+	errs := make([]error, len(v.members))
+	for i, member := range v.members {
+		if member.I2C == nil {
+			errs[i] = errors.New("sensor address is not assigned")
+			continue
+		}
+		if member.Sensor.continuous {
+			ranges[i], errs[i] = member.Sensor.ReadRangeContinuousMillimeters(member.I2C)
+		} else {
+			ranges[i], errs[i] = member.Sensor.ReadRangeSingleMillimeters(member.I2C)
+		}
+	}
+	return ranges, errs
+}
+
+// SnapshotAll take single-shot measurement from all sensors of the group
+// as close together in time as shared bus allows, and returns results
+// in the order of sensors addition. Sensors are prepared for measurement
+// first, then measurements are triggered one right after another, and only
+// then results are collected, so skew between sensors is limited to a few
+// register writes, rather than whole measurement time per sensor.
+// RangeData.Timestamp reports when each result was taken, to account
+// for residual skew. Continuous mode should not be active on any sensor.
+// Safe for concurrent use with other group methods.
+func (v *SensorGroup) SnapshotAll(ctx context.Context) ([]RangeData, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, member := range v.members {
+		if member.I2C == nil {
+			return nil, errors.New(spew.Sprintf("sensor %q address is not assigned",
+				member.Sensor.Label()))
+		}
+		if member.Sensor.continuous {
+			return nil, errors.New(spew.Sprintf("sensor %q is in continuous mode",
+				member.Sensor.Label()))
+		}
+	}
+
+	v.debug("Prepare group sensors for snapshot")
+
+	for _, member := range v.members {
+		sensor := member.Sensor
+		// clear interrupt possibly left pending by previous measurement
+		err := sensor.writeRegU8(member.I2C, SYSTEM_INTERRUPT_CLEAR, 0x01)
+		if err == nil {
+			err = sensor.writeStopVariable(member.I2C)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sensor %q: %w", sensor.Label(), err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, member := range v.members {
+		err := member.Sensor.writeSingleRangeStart(member.I2C)
+		if err != nil {
+			return nil, fmt.Errorf("sensor %q: %w", member.Sensor.Label(), err)
+		}
+	}
+
+	results := make([]RangeData, len(v.members))
+	var buf [resultBlockSize]byte
+	for i, member := range v.members {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		err := member.Sensor.readRangeInto(member.I2C, buf[:], &results[i])
+		if err != nil {
+			return nil, fmt.Errorf("sensor %q: %w", member.Sensor.Label(), err)
+		}
+	}
+	return results, nil
+}
+
+// Check that all sensors respond at assigned addresses,
+// and no sensor is left at DefaultAddress.
+func (v *SensorGroup) isHealthy() bool {
+	if v.probe(DefaultAddress) {
+		return false
+	}
+	for _, member := range v.members {
+		if member.I2C == nil {
+			return false
+		}
+		id, err := member.I2C.ReadRegU8(IDENTIFICATION_MODEL_ID)
+		if err != nil || id != modelID {
+			return false
+		}
+	}
+	return true
+}
+
+// Close turn off laser of each sensor of the group and mark it as closed
+// (see Vl53l0x.Close), then closes connections to all sensors.
+// To use sensors again, assign addresses (see AutoAssignAddresses)
+// and initialize each sensor.
+func (v *SensorGroup) Close() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var err error
+	for _, member := range v.members {
+		if member.I2C != nil {
+			err2 := member.Sensor.Close(member.I2C)
+			if err == nil {
+				err = err2
+			}
+			err2 = member.I2C.Close()
+			if err == nil {
+				err = err2
+			}
+			member.I2C = nil
+		}
+	}
+	return err
+}
+
+// Put all sensors of the group to hardware standby.
+func (v *SensorGroup) shutdownAll() error {
+	for _, member := range v.members {
+		if member.I2C != nil {
+			err := member.I2C.Close()
+			if err != nil {
+				return err
+			}
+			member.I2C = nil
+		}
+		member.Address = 0
+		err := member.SetXShut(false)
+		if err != nil {
+			return err
+		}
+		// hardware standby drops all sensor settings
+		member.Sensor.forgetState()
+	}
+	// let sensors power down
+	time.Sleep(time.Millisecond * 10)
+	return nil
+}
+
+// Release sensor from hardware standby, wait for boot,
+// then change sensor address and verify it.
+func (v *SensorGroup) bringUp(member *GroupMember, addr byte) error {
+	sensor := member.Sensor
+
+	sensor.debugf("Bring up sensor with address 0x%x", addr)
+
+	// sensor is reachable via new connection, even if it was closed,
+	// but should be initialized again
+	sensor.setClosed(false)
+
+	err := member.SetXShut(true)
+	if err != nil {
+		return err
+	}
+	conn, err := i2c.NewI2C(DefaultAddress, v.bus)
+	if err != nil {
+		return err
+	}
+	err = sensor.WaitForBoot(conn, bootTimeout)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defaultConn := conn
+	err = sensor.SetAddress(&conn, addr)
+	defaultConn.Close()
+	if err != nil {
+		return err
+	}
+	err = sensor.CheckModelID(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	member.I2C = conn
+	member.Address = addr
+	return nil
+}
+
+// ScanBus probe all valid addresses (0x08..0x77) of I2C-bus device devPath
+// (like "/dev/i2c-1") and returns those, where VL53L0X sensor answers with
+// expected model identifier. Each address is probed via short-lived connection
+// with zero-length write, which looks on the bus the same as SMBus quick write
+// used by "i2cdetect -q", and doesn't modify device state (see probeConn
+// for adapters, which don't support it). Model identifier register is read
+// only at addresses, which acknowledge probe; for devices with 8-bit register
+// addresses it just moves register pointer.
+func ScanBus(devPath string) ([]byte, error) {
+	bus, err := parseBusPath(devPath)
+	if err != nil {
+		return nil, err
+	}
+	var found []byte
+	for addr := byte(0x08); addr <= 0x77; addr++ {
+		conn, err := i2c.NewI2C(addr, bus)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// bus itself doesn't exist
+				return nil, err
+			}
+			// address is likely held by kernel driver
+			continue
+		}
+		if !probeConn(conn) {
+			conn.Close()
+			continue
+		}
+		id, err := conn.ReadRegU8(IDENTIFICATION_MODEL_ID)
+		conn.Close()
+		if err == nil && id == modelID {
+			labelDebugf(devPath, "Found sensor at address 0x%x", addr)
+			found = append(found, addr)
+		}
+	}
+	return found, nil
+}
+
+// Extract I2C-bus number from bus device path, like "/dev/i2c-1".
+func parseBusPath(devPath string) (int, error) {
+	const prefix = "/dev/i2c-"
+	if strings.HasPrefix(devPath, prefix) {
+		bus, err := strconv.Atoi(strings.TrimPrefix(devPath, prefix))
+		if err == nil && bus >= 0 {
+			return bus, nil
+		}
+	}
+	return 0, errors.New(spew.Sprintf("invalid I2C-bus device path %q, "+
+		"expected like \"/dev/i2c-1\"", devPath))
+}
+
+// Check that device acknowledge zero-length write (equivalent of SMBus
+// quick write), which doesn't modify device state. Some adapters reject
+// zero-length transfers with EOPNOTSUPP, then model identifier register
+// read is used instead.
+func probeConn(conn *i2c.I2C) bool {
+	_, err := conn.WriteBytes(nil)
+	if errors.Is(err, syscall.EOPNOTSUPP) {
+		labelDebugf("i2c-"+strconv.Itoa(conn.GetBus()), "Zero-length write isn't supported "+
+			"by adapter, probe address 0x%x with register read", conn.GetAddr())
+		_, err = conn.ReadRegU8(IDENTIFICATION_MODEL_ID)
+	}
+	return err == nil
+}
+
+// Check that device at address acknowledge probe (see probeConn).
+func (v *SensorGroup) probe(addr byte) bool {
+	conn, err := i2c.NewI2C(addr, v.bus)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	return probeConn(conn)
+}
+
+// Sibling sensors, which have 16-bit model identifier at 16-bit register
+// address 0x010F, so they could be recognized when model check fails.
+var siblingModels = []struct {
+	id   uint16
+	name string
+}{
+	{0xEACC, "VL53L1X"},
+	{0xEAAA, "VL53L3CX"},
+	{0xEBAA, "VL53L4CD/VL53L4CX"},
+}
+
+// SetProbeSiblingModels set whether CheckModelID attempts to recognize
+// sibling ST sensor (like VL53L1X, which is frequently confused with VL53L0X,
+// but isn't compatible), when model identifier doesn't match. Probe reads
+// 16-bit register address, which for ordinary device with 8-bit register
+// addresses means write to register 0x01, so enable it only when device
+// at the address is known to be one of ST ranging sensors. Disabled by default.
+func (v *Vl53l0x) SetProbeSiblingModels(enable bool) {
+	v.probeSiblingModels = enable
+}
+
+// CheckModelID verify that device is VL53L0X by reading its model identifier.
+// When identifier doesn't match, and sibling probe is enabled (see
+// SetProbeSiblingModels), attempt to recognize sibling ST sensor,
+// to return precise error.
+func (v *Vl53l0x) CheckModelID(i2c *i2c.I2C) error {
+	id, err := v.readRegU8(i2c, IDENTIFICATION_MODEL_ID)
+	if err != nil {
+		return err
+	}
+	if id == modelID {
+		return nil
+	}
+	if !v.probeSiblingModels {
+		return errors.New(spew.Sprintf("unexpected model ID 0x%x, device is not a VL53L0X; "+
+			"it may be a VL53L1X or another ST sibling sensor, not supported by this package "+
+			"(enable SetProbeSiblingModels to recognize it)", id))
+	}
+	v.debugf("Unexpected model ID 0x%x, check for sibling sensors", id)
+	// Device is expected to be ST sensor, so it's safe to use 16-bit register address.
+	if _, err := i2c.WriteBytes([]byte{0x01, 0x0F}); err == nil {
+		var buf [2]byte
+		if _, err := i2c.ReadBytes(buf[:]); err == nil {
+			id16 := uint16(buf[0])<<8 | uint16(buf[1])
+			for _, model := range siblingModels {
+				if id16 == model.id {
+					return errors.New(spew.Sprintf("this is a %s, not supported by this package",
+						model.name))
+				}
+			}
+		}
+	}
+	return errors.New(spew.Sprintf("unexpected model ID 0x%x, device is not a VL53L0X", id))
+}
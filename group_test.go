@@ -0,0 +1,27 @@
+package vl53l0x
+
+import "testing"
+
+func TestParseBusPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{"/dev/i2c-1", 1, false},
+		{"/dev/i2c-10", 10, false},
+		{"/dev/i2c-", 0, true},
+		{"/dev/i2c-1x", 0, true},
+		{"/dev/i2c--1", 0, true},
+		{"/dev/spidev0.0", 0, true},
+		{"1", 0, true},
+	}
+	for _, test := range tests {
+		got, err := parseBusPath(test.path)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseBusPath(%q): unexpected error %v", test.path, err)
+		} else if got != test.want {
+			t.Errorf("parseBusPath(%q) = %d, want %d", test.path, got, test.want)
+		}
+	}
+}
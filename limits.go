@@ -0,0 +1,159 @@
+package vl53l0x
+
+import (
+	"errors"
+
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// LimitCheck identify measurement validity check,
+// numbered the same way as in VL53L0X API.
+type LimitCheck int
+
+const (
+	// Sigma (measurement noise estimate) check of final range.
+	LimitCheckSigmaFinalRange LimitCheck = 0
+	// Return signal rate check of final range (see SetSignalRateLimit).
+	LimitCheckSignalRateFinalRange LimitCheck = 1
+	// Range ignore threshold check (see SetRangeIgnoreThreshold).
+	LimitCheckRangeIgnoreThreshold LimitCheck = 3
+	// Return signal rate check of MSRC (minimum signal rate check) step.
+	LimitCheckSignalRateMsrc LimitCheck = 4
+	// Return signal rate check of pre-range step.
+	LimitCheckSignalRatePreRange LimitCheck = 5
+)
+
+// String implement Stringer interface.
+func (v LimitCheck) String() string {
+	switch v {
+	case LimitCheckSigmaFinalRange:
+		return "SIGMA_FINAL_RANGE"
+	case LimitCheckSignalRateFinalRange:
+		return "SIGNAL_RATE_FINAL_RANGE"
+	case LimitCheckRangeIgnoreThreshold:
+		return "RANGE_IGNORE_THRESHOLD"
+	case LimitCheckSignalRateMsrc:
+		return "SIGNAL_RATE_MSRC"
+	case LimitCheckSignalRatePreRange:
+		return "SIGNAL_RATE_PRE_RANGE"
+	default:
+		return "<unknown>"
+	}
+}
+
+// Bits of MSRC_CONFIG_CONTROL register, which disable limit checks.
+const (
+	msrcControlDisableMsrc     = 0x02
+	msrcControlDisablePreRange = 0x10
+)
+
+// SetLimitCheckEnable enable or disable measurement validity check.
+// Init disables SIGNAL_RATE_MSRC and SIGNAL_RATE_PRE_RANGE checks, and enables
+// SIGNAL_RATE_FINAL_RANGE one. SIGMA_FINAL_RANGE and RANGE_IGNORE_THRESHOLD
+// checks are performed by the library, and disabled by default. Disabling
+// SIGNAL_RATE_FINAL_RANGE check sets signal rate limit to zero, and enabling
+// it restores limit back; note, that SetSignalRateLimit with non-zero value
+// enables check as well.
+// Based on VL53L0X_SetLimitCheckEnable().
+func (v *Vl53l0x) SetLimitCheckEnable(i2c *i2c.I2C, check LimitCheck, enabled bool) error {
+
+	v.debugf("Set limit check %v enabled to %v", check, enabled)
+
+	switch check {
+	case LimitCheckSignalRateMsrc, LimitCheckSignalRatePreRange:
+		bit := byte(msrcControlDisableMsrc)
+		if check == LimitCheckSignalRatePreRange {
+			bit = msrcControlDisablePreRange
+		}
+		u8, err := v.readRegU8(i2c, MSRC_CONFIG_CONTROL)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			u8 &^= bit
+		} else {
+			u8 |= bit
+		}
+		return v.writeRegU8(i2c, MSRC_CONFIG_CONTROL, u8)
+	case LimitCheckSignalRateFinalRange:
+		u16, err := v.readRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT)
+		if err != nil {
+			return err
+		}
+		if enabled {
+			if u16 != 0 {
+				// already enabled
+				return nil
+			}
+			limit := v.disabledSignalRateLimit
+			if limit == 0 {
+				// default value set by Init
+				limit = 0.25 * (1 << 7)
+			}
+			return v.writeRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT, limit)
+		}
+		if u16 != 0 {
+			v.disabledSignalRateLimit = u16
+		}
+		return v.writeRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT, 0)
+	case LimitCheckRangeIgnoreThreshold:
+		v.rangeIgnoreEnabled = enabled
+		return nil
+	case LimitCheckSigmaFinalRange:
+		if enabled && v.sigmaLimitMm == 0 {
+			v.sigmaLimitMm = defaultSigmaLimitMm
+		}
+		v.sigmaCheckEnabled = enabled
+		return nil
+	default:
+		return errors.New("invalid limit check specified")
+	}
+}
+
+// GetLimitCheckEnable returns true, if measurement validity check is enabled.
+// Based on VL53L0X_GetLimitCheckEnable().
+func (v *Vl53l0x) GetLimitCheckEnable(i2c *i2c.I2C, check LimitCheck) (bool, error) {
+	switch check {
+	case LimitCheckSignalRateMsrc, LimitCheckSignalRatePreRange:
+		bit := byte(msrcControlDisableMsrc)
+		if check == LimitCheckSignalRatePreRange {
+			bit = msrcControlDisablePreRange
+		}
+		u8, err := v.readRegU8(i2c, MSRC_CONFIG_CONTROL)
+		if err != nil {
+			return false, err
+		}
+		return u8&bit == 0, nil
+	case LimitCheckSignalRateFinalRange:
+		u16, err := v.readRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT)
+		if err != nil {
+			return false, err
+		}
+		return u16 != 0, nil
+	case LimitCheckRangeIgnoreThreshold:
+		return v.rangeIgnoreEnabled, nil
+	case LimitCheckSigmaFinalRange:
+		return v.sigmaCheckEnabled, nil
+	default:
+		return false, errors.New("invalid limit check specified")
+	}
+}
+
+// GetLimitCheckStatus returns limit checks status of last measurement result
+// read by any measurement method: true means that check failed. Sensor doesn't
+// report SIGNAL_RATE_MSRC and SIGNAL_RATE_PRE_RANGE check failures separately
+// (like in ST API they are not included), weak signal is reported as
+// SIGNAL_RATE_FINAL_RANGE failure. Disabled checks are reported as passed.
+// Based on VL53L0X_GetLimitCheckStatus().
+func (v *Vl53l0x) GetLimitCheckStatus() (map[LimitCheck]bool, error) {
+	const SignalFail = 4
+	status := v.decodeDeviceRangeStatus(v.lastRangeStatus)
+	if status == 0 {
+		return nil, errors.New("no measurement result available")
+	}
+	return map[LimitCheck]bool{
+		LimitCheckSigmaFinalRange:      v.lastSigmaFail,
+		LimitCheckSignalRateFinalRange: status == SignalFail,
+		LimitCheckRangeIgnoreThreshold: v.lastRangeIgnoreFail,
+	}, nil
+}
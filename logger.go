@@ -1,6 +1,10 @@
 package vl53l0x
 
-import logger "github.com/d2r2/go-logger"
+import (
+	"strconv"
+
+	logger "github.com/d2r2/go-logger"
+)
 
 // You can manage verbosity of log output
 // in the package by changing last parameter value.
@@ -8,3 +12,80 @@ var lg = logger.NewPackageLogger("vl53l0x",
 	logger.DebugLevel,
 	// logger.InfoLevel,
 )
+
+// SetLogLevel set log output verbosity of this sensor, additionally to
+// package log level, which is changed via logger.ChangePackageLogLevel("vl53l0x", ...)
+// and affects all sensors. Messages less important than level are suppressed.
+func (v *Vl53l0x) SetLogLevel(level logger.LogLevel) {
+	v.logLevel = level
+	v.logLevelSet = true
+}
+
+// Check that messages with level should be logged for this sensor.
+func (v *Vl53l0x) logEnabled(level logger.LogLevel) bool {
+	return !v.logLevelSet || level <= v.logLevel
+}
+
+// Output debug message prefixed with sensor label, if specified.
+func (v *Vl53l0x) debug(args ...interface{}) {
+	if !v.logEnabled(logger.DebugLevel) {
+		return
+	}
+	if v.label != "" {
+		lg.Debug(append([]interface{}{"[" + v.label + "] "}, args...)...)
+		return
+	}
+	lg.Debug(args...)
+}
+
+// Output formatted debug message prefixed with sensor label, if specified.
+func (v *Vl53l0x) debugf(format string, args ...interface{}) {
+	if !v.logEnabled(logger.DebugLevel) {
+		return
+	}
+	if v.label != "" {
+		lg.Debugf("["+v.label+"] "+format, args...)
+		return
+	}
+	lg.Debugf(format, args...)
+}
+
+// Output formatted warning message prefixed with sensor label, if specified.
+func (v *Vl53l0x) warningf(format string, args ...interface{}) {
+	if !v.logEnabled(logger.WarnLevel) {
+		return
+	}
+	if v.label != "" {
+		lg.Warningf("["+v.label+"] "+format, args...)
+		return
+	}
+	lg.Warningf(format, args...)
+}
+
+// Output formatted error message prefixed with sensor label, if specified.
+func (v *Vl53l0x) errorf(format string, args ...interface{}) {
+	if !v.logEnabled(logger.ErrorLevel) {
+		return
+	}
+	if v.label != "" {
+		lg.Errorf("["+v.label+"] "+format, args...)
+		return
+	}
+	lg.Errorf(format, args...)
+}
+
+// Output debug message prefixed with sensor group label,
+// to tell group messages from sensor ones.
+func (v *SensorGroup) debug(args ...interface{}) {
+	lg.Debug(append([]interface{}{"[" + v.label() + "] "}, args...)...)
+}
+
+// Sensor group label used in log messages.
+func (v *SensorGroup) label() string {
+	return "group i2c-" + strconv.Itoa(v.bus)
+}
+
+// Output formatted debug message prefixed with label.
+func labelDebugf(label string, format string, args ...interface{}) {
+	lg.Debugf("["+label+"] "+format, args...)
+}
@@ -0,0 +1,240 @@
+package vl53l0x
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// ReadRangeContinuousAveraged returns average of next samples range readings
+// in millimeters, when continuous mode is active (see StartContinuous).
+// Outliers (readings deviating from median more than 3 median absolute
+// deviations) are excluded from average.
+func (v *Vl53l0x) ReadRangeContinuousAveraged(i2c *i2c.I2C, samples int) (uint16, error) {
+
+	v.debugf("Read range continuous averaged over %d samples", samples)
+
+	if !v.continuous {
+		return 0, errors.New("continuous mode is not active")
+	}
+	if samples <= 0 {
+		return 0, errors.New("samples count should be positive")
+	}
+	values := make([]uint16, samples)
+	for i := range values {
+		rng, err := v.readRangeMillimeters(i2c)
+		if err != nil {
+			return 0, err
+		}
+		values[i] = rng
+	}
+	return averageWithoutOutliers(values), nil
+}
+
+// ReadRangeStable take measurements repeatedly, until last window valid
+// readings are all within tolerance millimeters of each other, and returns
+// their mean. Invalid reading (error range status, or out of range distance)
+// restarts the window. Uses continuous measurement results, if continuous mode
+// is active, otherwise single-shot measurements. Error matching ErrTimeout
+// is returned, if readings don't converge within timeout (zero value means
+// no limit). Helpful to find out, when sensor
+// and target are settled before recording calibration point.
+func (v *Vl53l0x) ReadRangeStable(i2c *i2c.I2C, tolerance uint16, window int,
+	timeout time.Duration) (uint16, error) {
+
+	v.debugf("Read range stable within %d mm over %d readings", tolerance, window)
+
+	if window <= 0 {
+		return 0, errors.New("window size should be positive")
+	}
+	values := make([]uint16, 0, window)
+	var buf [resultBlockSize]byte
+	var data RangeData
+	st := time.Now()
+	for {
+		err := v.ReadRangeDataInto(i2c, buf[:], &data)
+		if err != nil {
+			return 0, err
+		}
+		if !v.isRangeValid(&data) {
+			values = values[:0]
+		} else {
+			if len(values) == window {
+				copy(values, values[1:])
+				values = values[:window-1]
+			}
+			values = append(values, data.RangeMm)
+			if len(values) == window {
+				minValue, maxValue := values[0], values[0]
+				sum := 0
+				for _, value := range values {
+					if value < minValue {
+						minValue = value
+					}
+					if value > maxValue {
+						maxValue = value
+					}
+					sum += int(value)
+				}
+				if maxValue-minValue <= tolerance {
+					return uint16((sum + window/2) / window), nil
+				}
+			}
+		}
+		if timeout > 0 && time.Since(st) > timeout {
+			return 0, fmt.Errorf("range didn't stabilize: %w", ErrTimeout)
+		}
+	}
+}
+
+// Reading keeps single measurement result returned by ReadRangeBatch.
+type Reading struct {
+	// Measured distance in millimeters.
+	RangeMm uint16
+	// Unmodified RESULT_RANGE_STATUS register value (see ReadRawRangeStatus).
+	RangeStatus byte
+	// Time when measurement result was taken from the sensor.
+	Timestamp time.Time
+}
+
+// ReadRangeBatch returns n consecutive measurement results. When continuous
+// mode is active, next continuous measurement results are taken; otherwise
+// single-shot measurements are performed, writing stop variable preamble
+// only once for the whole batch. If measurement fails partway, readings
+// taken so far are returned along with error.
+func (v *Vl53l0x) ReadRangeBatch(i2c *i2c.I2C, n int) ([]Reading, error) {
+
+	v.debugf("Read range batch of %d readings", n)
+
+	if n <= 0 {
+		return nil, errors.New("readings count should be positive")
+	}
+	readings := make([]Reading, 0, n)
+	var buf [resultBlockSize]byte
+	var data RangeData
+	for i := 0; i < n; i++ {
+		var err error
+		if !v.continuous {
+			if i == 0 {
+				err = v.startSingleRange(i2c)
+			} else {
+				err = v.triggerSingleRange(i2c)
+			}
+		}
+		if err == nil {
+			err = v.readRangeInto(i2c, buf[:], &data)
+		}
+		if err != nil {
+			return readings, err
+		}
+		readings = append(readings, Reading{RangeMm: data.RangeMm,
+			RangeStatus: data.RangeStatus, Timestamp: data.Timestamp})
+	}
+	return readings, nil
+}
+
+// MeasureCadence run continuous mode with periodMs inter-measurement period
+// (see StartContinuous) and measure time intervals between samples+1
+// consecutive "data ready" events, returning intervals mean and standard
+// deviation. Helps to find out how stable measurement period is.
+func (v *Vl53l0x) MeasureCadence(i2c *i2c.I2C, periodMs uint32,
+	samples int) (mean, stddev time.Duration, err error) {
+
+	if samples <= 0 {
+		return 0, 0, errors.New("samples count should be positive")
+	}
+	err = v.StartContinuous(i2c, periodMs)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		err2 := v.StopContinuous(i2c)
+		if err == nil {
+			err = err2
+		}
+	}()
+
+	intervals := make([]time.Duration, samples)
+	var last time.Time
+	for i := -1; i < samples; i++ {
+		_, err = v.readRangeMillimeters(i2c)
+		if err != nil {
+			return 0, 0, err
+		}
+		now := time.Now()
+		if i >= 0 {
+			intervals[i] = now.Sub(last)
+		}
+		last = now
+	}
+
+	var sum time.Duration
+	for _, interval := range intervals {
+		sum += interval
+	}
+	mean = sum / time.Duration(samples)
+	var sumSquares float64
+	for _, interval := range intervals {
+		diff := float64(interval - mean)
+		sumSquares += diff * diff
+	}
+	stddev = time.Duration(math.Sqrt(sumSquares / float64(samples)))
+
+	v.debugf("Measurement cadence: mean = %v, stddev = %v", mean, stddev)
+
+	return mean, stddev, nil
+}
+
+// EffectiveMeasurementPeriod returns expected interval between measurements
+// in continuous back-to-back mode, which is the measurement timing budget
+// calculated from actual sequence configuration and step timeouts, including
+// sequence overheads (see QueryTimingBudget). In continuous timed mode
+// measurements are taken with inter-measurement period, unless it's shorter.
+// Use MeasureCadence to find out actual period.
+func (v *Vl53l0x) EffectiveMeasurementPeriod(i2c *i2c.I2C) (time.Duration, error) {
+	budgetUsec, err := v.QueryTimingBudget(i2c)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(budgetUsec) * time.Microsecond, nil
+}
+
+// Calculate average of values, excluding outliers, which deviate
+// from median more than 3 median absolute deviations.
+func averageWithoutOutliers(values []uint16) uint16 {
+	sorted := make([]int, len(values))
+	for i, value := range values {
+		sorted[i] = int(value)
+	}
+	sort.Ints(sorted)
+	median := sorted[len(sorted)/2]
+
+	deviations := make([]int, len(sorted))
+	for i, value := range sorted {
+		deviations[i] = abs(value - median)
+	}
+	sort.Ints(deviations)
+	mad := deviations[len(deviations)/2]
+
+	var sum, count int
+	for _, value := range sorted {
+		if abs(value-median) <= 3*mad {
+			sum += value
+			count++
+		}
+	}
+	// count is never zero, since median itself always pass
+	return uint16((sum + count/2) / count)
+}
+
+// Return absolute value of integer.
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,60 @@
+package vl53l0x
+
+import "sync/atomic"
+
+// Metrics keeps sensor operation counters, which are safe
+// to read concurrently with sensor operation.
+// Attach it to sensor with Vl53l0x.SetMetrics.
+type Metrics struct {
+	// keep 64-bit fields first to guarantee alignment
+	// required by atomic operations on 32-bit platforms
+	measurements uint64
+	timeouts     uint64
+	busErrors    uint64
+	outOfRange   uint64
+}
+
+// Measurements returns number of measurement results taken from sensor.
+func (v *Metrics) Measurements() uint64 {
+	return atomic.LoadUint64(&v.measurements)
+}
+
+// Timeouts returns number of timeouts occurred, waiting for sensor events.
+func (v *Metrics) Timeouts() uint64 {
+	return atomic.LoadUint64(&v.timeouts)
+}
+
+// BusErrors returns number of failed I2C-bus read/write operations.
+// Note, that it includes errors expected while sensor reboots during Reset.
+func (v *Metrics) BusErrors() uint64 {
+	return atomic.LoadUint64(&v.busErrors)
+}
+
+// OutOfRange returns number of measurements, where no target was detected.
+func (v *Metrics) OutOfRange() uint64 {
+	return atomic.LoadUint64(&v.outOfRange)
+}
+
+// Count measurement result with distance rng; no-op for nil metrics.
+func (v *Metrics) addMeasurement(rng uint16) {
+	if v != nil {
+		atomic.AddUint64(&v.measurements, 1)
+		if rng >= outOfRange {
+			atomic.AddUint64(&v.outOfRange, 1)
+		}
+	}
+}
+
+// Count timeout; no-op for nil metrics.
+func (v *Metrics) addTimeout() {
+	if v != nil {
+		atomic.AddUint64(&v.timeouts, 1)
+	}
+}
+
+// Count bus error, if err is not nil; no-op for nil metrics.
+func (v *Metrics) addBusError(err error) {
+	if v != nil && err != nil {
+		atomic.AddUint64(&v.busErrors, 1)
+	}
+}
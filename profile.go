@@ -0,0 +1,85 @@
+package vl53l0x
+
+import (
+	"errors"
+	"sync"
+
+	i2c "github.com/d2r2/go-i2c"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Profile keeps named sensor configuration, which
+// could be applied at once by ApplyProfile.
+type Profile struct {
+	// Return signal rate limit in MCPS (see SetSignalRateLimit).
+	SignalRateLimitMcps float32
+	// Pre-range VCSEL pulse period in PCLKs (see SetVcselPulsePeriod).
+	PreRangeVcselPeriodPclks uint8
+	// Final range VCSEL pulse period in PCLKs (see SetVcselPulsePeriod).
+	FinalRangeVcselPeriodPclks uint8
+	// Measurement timing budget in microseconds (see SetMeasurementTimingBudget).
+	TimingBudgetUsec uint32
+}
+
+var (
+	profilesMutex sync.RWMutex
+	profiles      = make(map[string]Profile)
+)
+
+// Register built-in profiles for each combination of RangeSpec
+// and SpeedAccuracySpec, named like "RegularRange/GoodAccuracy".
+func init() {
+	for _, rng := range []RangeSpec{RegularRange, LongRange} {
+		limitMcps, prePclks, finalPclks, _ := rangeSpecSettings(rng)
+		for _, speed := range []SpeedAccuracySpec{HighSpeed, RegularAccuracy,
+			GoodAccuracy, HighAccuracy, HighestAccuracy} {
+
+			budgetUsec, _ := speedAccuracySpecBudget(speed)
+			RegisterProfile(ProfileName(rng, speed), Profile{
+				SignalRateLimitMcps:        limitMcps,
+				PreRangeVcselPeriodPclks:   prePclks,
+				FinalRangeVcselPeriodPclks: finalPclks,
+				TimingBudgetUsec:           budgetUsec,
+			})
+		}
+	}
+}
+
+// ProfileName returns name of built-in profile equal to Config
+// with corresponding RangeSpec and SpeedAccuracySpec.
+func ProfileName(rng RangeSpec, speed SpeedAccuracySpec) string {
+	return rng.String() + "/" + speed.String()
+}
+
+// RegisterProfile add named profile to the registry,
+// replacing existing one with the same name.
+func RegisterProfile(name string, p Profile) {
+	profilesMutex.Lock()
+	defer profilesMutex.Unlock()
+	profiles[name] = p
+}
+
+// GetProfile returns profile registered with the name.
+func GetProfile(name string) (Profile, bool) {
+	profilesMutex.RLock()
+	defer profilesMutex.RUnlock()
+	p, ok := profiles[name]
+	return p, ok
+}
+
+// ApplyProfile configure sensor with profile registered with the name.
+func (v *Vl53l0x) ApplyProfile(i2c *i2c.I2C, name string) error {
+	p, ok := GetProfile(name)
+	if !ok {
+		return errors.New(spew.Sprintf("profile %q is not registered", name))
+	}
+
+	v.debugf("Apply profile %q", name)
+
+	err := v.applyRangeSettings(i2c, p.SignalRateLimitMcps,
+		p.PreRangeVcselPeriodPclks, p.FinalRangeVcselPeriodPclks)
+	if err != nil {
+		return err
+	}
+	return v.SetMeasurementTimingBudget(i2c, p.TimingBudgetUsec)
+}
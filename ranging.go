@@ -0,0 +1,215 @@
+package vl53l0x
+
+import (
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// Range status values of RangingMeasurementData,
+// same as in VL53L0X API (see VL53L0X_GetRangeStatusString).
+const (
+	RangeStatusValid        = 0
+	RangeStatusSigmaFail    = 1
+	RangeStatusSignalFail   = 2
+	RangeStatusMinRangeFail = 3
+	RangeStatusPhaseFail    = 4
+	RangeStatusHardwareFail = 5
+	RangeStatusNoUpdate     = 255
+)
+
+// RangingMeasurementData keeps measurement result with the same field names
+// as VL53L0X_RangingMeasurementData_t structure of VL53L0X API,
+// to simplify porting of code based on ST API.
+type RangingMeasurementData struct {
+	// Measured distance in millimeters.
+	RangeMilliMeter uint16
+	// Maximum detection distance in current setup and environment conditions.
+	// Estimated by the library the same way as by ST API.
+	RangeDMaxMilliMeter uint16
+	// Return signal rate in MCPS.
+	SignalRateRtnMegaCps float32
+	// Return ambient rate in MCPS.
+	AmbientRateRtnMegaCps float32
+	// Effective SPAD count for return signal.
+	EffectiveSpadRtnCount float32
+	// Range status (see RangeStatus... constants).
+	RangeStatus byte
+}
+
+// GetRangingMeasurementData read result of last measurement from the sensor.
+// Doesn't wait for measurement to complete, so should be called once
+// measurement result is ready, like VL53L0X_GetRangingMeasurementData.
+func (v *Vl53l0x) GetRangingMeasurementData(i2c *i2c.I2C) (*RangingMeasurementData, error) {
+
+	v.debug("Get ranging measurement data")
+
+	buf, err := v.readResultBlock(i2c)
+	if err != nil {
+		return nil, err
+	}
+	return v.decodeRangingMeasurementData(buf), nil
+}
+
+// Offsets of return signal and ambient rates in result block.
+const (
+	resultSignalRateOffset  = 6
+	resultAmbientRateOffset = 8
+)
+
+// GetSignalRate read return signal rate in MCPS of the most recent
+// measurement from the sensor, without starting a new measurement
+// and waiting for it. Useful for lightweight monitoring, while
+// continuous mode is active.
+func (v *Vl53l0x) GetSignalRate(i2c *i2c.I2C) (float32, error) {
+	u16, err := v.readRegU16(i2c, RESULT_RANGE_STATUS+resultSignalRateOffset)
+	if err != nil {
+		return 0, err
+	}
+	return FixedToMCPS(u16), nil
+}
+
+// GetAmbientRate read return ambient rate in MCPS of the most recent
+// measurement from the sensor, without starting a new measurement
+// and waiting for it. Useful to monitor ambient light conditions.
+func (v *Vl53l0x) GetAmbientRate(i2c *i2c.I2C) (float32, error) {
+	u16, err := v.readRegU16(i2c, RESULT_RANGE_STATUS+resultAmbientRateOffset)
+	if err != nil {
+		return 0, err
+	}
+	return FixedToMCPS(u16), nil
+}
+
+// Perform single-shot range measurement and return
+// result as RangingMeasurementData. Measurement is taken the same way
+// as by other single-shot methods, so measurement is counted in sequence,
+// and library limit checks are applied.
+func (v *Vl53l0x) measureRangingData(i2c *i2c.I2C) (*RangingMeasurementData, error) {
+	err := v.startSingleRange(i2c)
+	if err != nil {
+		return nil, err
+	}
+	var buf [resultBlockSize]byte
+	var rangeData RangeData
+	err = v.readRangeInto(i2c, buf[:], &rangeData)
+	if err != nil {
+		return nil, err
+	}
+	data := v.decodeRangingMeasurementData(buf)
+	// like in ST API, sigma check takes precedence over range ignore
+	// threshold check, which failure is reported as signal fail
+	if data.RangeStatus == RangeStatusValid && v.lastSigmaFail {
+		data.RangeStatus = RangeStatusSigmaFail
+	}
+	if data.RangeStatus == RangeStatusValid && v.lastRangeIgnoreFail {
+		data.RangeStatus = RangeStatusSignalFail
+	}
+	return data, nil
+}
+
+// Decode result block to RangingMeasurementData.
+func (v *Vl53l0x) decodeRangingMeasurementData(buf [resultBlockSize]byte) *RangingMeasurementData {
+	data := &RangingMeasurementData{
+		RangeMilliMeter: uint16(buf[10])<<8 | uint16(buf[11]),
+		// Q9.7 fixed point format (9 integer bits, 7 fractional bits)
+		SignalRateRtnMegaCps:  FixedToMCPS(uint16(buf[6])<<8 | uint16(buf[7])),
+		AmbientRateRtnMegaCps: FixedToMCPS(uint16(buf[8])<<8 | uint16(buf[9])),
+		// 8.8 fixed point format
+		EffectiveSpadRtnCount: float32(uint16(buf[2])<<8|uint16(buf[3])) / (1 << 8),
+		RangeStatus:           v.palRangeStatus(buf[0]),
+	}
+	sigmaMm, dmaxMm := v.estimateSigma(buf[:])
+	data.RangeDMaxMilliMeter = uint16(dmaxMm)
+	if data.RangeStatus == RangeStatusValid && v.isSigmaFail(sigmaMm) {
+		data.RangeStatus = RangeStatusSigmaFail
+	}
+	return data
+}
+
+// Convert device range status to range status reported by VL53L0X API.
+// Based on VL53L0X_get_pal_range_status, without sigma and
+// signal limit checks done in software.
+func (v *Vl53l0x) palRangeStatus(rangeStatus byte) byte {
+	switch v.decodeDeviceRangeStatus(rangeStatus) {
+	case 1, 2, 3:
+		return RangeStatusHardwareFail
+	case 4:
+		return RangeStatusSignalFail
+	case 6, 9:
+		return RangeStatusPhaseFail
+	case 8, 10:
+		return RangeStatusMinRangeFail
+	case 11:
+		return RangeStatusValid
+	default:
+		return RangeStatusNoUpdate
+	}
+}
+
+// DetailedRangeData keeps measurement result with additional
+// information, which helps to reason about its ambiguity.
+type DetailedRangeData struct {
+	RangeData
+	// Device range status, decoded from RangeStatus.
+	DeviceRangeStatus byte
+	// Return signal rate in MCPS.
+	SignalRateMcps float32
+	// Return ambient rate in MCPS.
+	AmbientRateMcps float32
+	// Effective SPAD count for return signal.
+	EffectiveSpadCount float32
+	// Final range VCSEL pulse period in PCLKs.
+	FinalRangeVcselPeriodPclks uint8
+	// Distance, where return signal phase wraps around for the final
+	// range VCSEL pulse period: target located farther could be reported
+	// at distance reduced by multiple of this value.
+	UnambiguousRangeMm uint16
+}
+
+// ReadRangeDetailed performs range measurement like ReadRangeData does,
+// and returns measurement result with return signal details and unambiguous
+// range of current final range VCSEL pulse period. Sensor doesn't report
+// raw phase of return signal, but together with RangeData.Wrapped it lets
+// reason about wrap-around ambiguity. Use single-shot measurement,
+// unless continuous mode is active.
+func (v *Vl53l0x) ReadRangeDetailed(i2c *i2c.I2C) (*DetailedRangeData, error) {
+
+	v.debug("Read range detailed")
+
+	periodPclks := uint8(v.finalRangeVcselPclks)
+	if periodPclks == 0 {
+		var err error
+		periodPclks, err = v.getVcselPulsePeriod(i2c, VcselPeriodFinalRange)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf [resultBlockSize]byte
+	data := &DetailedRangeData{}
+	if !v.continuous {
+		err := v.startSingleRange(i2c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := v.readRangeInto(i2c, buf[:], &data.RangeData)
+	if err != nil {
+		return nil, err
+	}
+	ranging := v.decodeRangingMeasurementData(buf)
+	data.DeviceRangeStatus = v.decodeDeviceRangeStatus(buf[0])
+	data.SignalRateMcps = ranging.SignalRateRtnMegaCps
+	data.AmbientRateMcps = ranging.AmbientRateRtnMegaCps
+	data.EffectiveSpadCount = ranging.EffectiveSpadRtnCount
+	data.FinalRangeVcselPeriodPclks = periodPclks
+	data.UnambiguousRangeMm = v.unambiguousRange(periodPclks)
+	return data, nil
+}
+
+// Calculate distance in millimeters, which light travels forth and back
+// during one VCSEL pulse period (PLL period is 1655 ps).
+func (v *Vl53l0x) unambiguousRange(vcselPeriodPclks uint8) uint16 {
+	const PllPeriodPs = 1655
+	// speed of light in air in mm per ps
+	const SpeedOfLightMmPerPs = 0.2997
+	return uint16(float32(vcselPeriodPclks) * PllPeriodPs * SpeedOfLightMmPerPs / 2)
+}
@@ -0,0 +1,209 @@
+package vl53l0x
+
+import (
+	"errors"
+	"math"
+)
+
+// Default sigma limit used by VL53L0X API.
+const defaultSigmaLimitMm = 18
+
+// SetSigmaLimit set sigma (estimated measurement noise) limit in millimeters
+// and enable sigma limit check, so measurement with sigma estimate exceeding
+// limit is treated as "no target" and distance is reported as 8190 mm
+// (out of range). Zero value disables check.
+//
+// Like in ST API, check is performed by the library, not by the sensor:
+// sigma is estimated from return signal rate, ambient rate, effective SPAD
+// count and sequence step timings, so there is no register to keep limit.
+// ST API keeps limit in FixPoint16.16 format (1/65536 mm resolution).
+// Based on VL53L0X_SetLimitCheckValue() and VL53L0X_calc_sigma_estimate().
+func (v *Vl53l0x) SetSigmaLimit(limitMm float32) error {
+	if limitMm < 0 || limitMm > 65535 {
+		return errors.New("sigma limit is out of range")
+	}
+	v.sigmaLimitMm = limitMm
+	v.sigmaCheckEnabled = limitMm != 0
+	return nil
+}
+
+// GetSigmaLimit returns sigma limit in millimeters specified
+// by SetSigmaLimit, or zero if sigma limit check is disabled.
+// Unlike other limit getters, no error is returned, since limit
+// is kept by the library and isn't read from the sensor.
+func (v *Vl53l0x) GetSigmaLimit() float32 {
+	if !v.sigmaCheckEnabled {
+		return 0
+	}
+	return v.sigmaLimitMm
+}
+
+// Default calibration distance and return signal rate of DMax estimate,
+// set by VL53L0X_DataInit() ("no cover glass").
+const (
+	dmaxCalRangeMm        = 400
+	dmaxCalSignalRateMcps = 1.42
+)
+
+// Estimate sigma and DMax (maximum detection distance) in millimeters
+// for measurement result kept in result block.
+func (v *Vl53l0x) estimateSigma(buf []byte) (sigmaMm, dmaxMm float32) {
+	const RangeValid = 11
+	rangeMm := float32(uint16(buf[10])<<8 | uint16(buf[11]))
+	// Q9.7 fixed point format
+	signalMcps := FixedToMCPS(uint16(buf[6])<<8 | uint16(buf[7]))
+	ambientMcps := FixedToMCPS(uint16(buf[8])<<8 | uint16(buf[9]))
+	// 8.8 fixed point format
+	spadCount := float32(uint16(buf[2])<<8|uint16(buf[3])) / (1 << 8)
+	valid := v.decodeDeviceRangeStatus(buf[0]) == RangeValid
+
+	return v.sigmaEstimate(rangeMm, valid, signalMcps, ambientMcps, spadCount)
+}
+
+// Check that sigma estimate exceeds sigma limit, when sigma limit check is enabled.
+func (v *Vl53l0x) isSigmaFail(sigmaMm float32) bool {
+	if v.sigmaCheckEnabled && sigmaMm > v.sigmaLimitMm {
+		v.debugf("Sigma estimate %v mm exceeds limit %v mm", sigmaMm, v.sigmaLimitMm)
+		return true
+	}
+	return false
+}
+
+// Estimate measurement sigma and DMax in millimeters. Floating point adaptation
+// of VL53L0X_calc_sigma_estimate(), which use fixed point arithmetic.
+func (v *Vl53l0x) sigmaEstimate(rangeMm float32, valid bool,
+	signalMcps, ambientMcps, spadCount float32) (sigmaMm, dmaxMm float32) {
+
+	const PulseEffectiveWidthCentiNs = 800
+	const AmbientEffectiveWidthCentiNs = 600
+	const DfltFinalRangeIntegrationTimeMs = 25
+	const VcselPulseWidthPs = 4700
+	const SigmaEstMax = 655.53
+	const SigmaEstRtnMax = 0.9375
+	const AmbToSignalRatioMax = 102.4
+	// time of flight per mm in picoseconds
+	const TofPerMmPs = 6.6
+	const MaxXTalkKcps = 50
+	const PllPeriodPs = 1655
+	const SpeedOfLightInAir = 2997
+
+	xTalkMcps := v.xTalkCompensationRateMcps * spadCount
+	totalSignalMcps := signalMcps + xTalkMcps
+	// "signal rate measurement provided by device
+	// is the peak signal rate, not average"
+	peakSignalKcps := totalSignalMcps * 1000
+	xTalkKcps := xTalkMcps * 1000
+	if xTalkKcps > MaxXTalkKcps {
+		xTalkKcps = MaxXTalkKcps
+	}
+	integrationTimeMs := float32(v.finalRangeTimeoutUsec+v.preRangeTimeoutUsec) / 1000
+	if peakSignalKcps < 1 || integrationTimeMs <= 0 {
+		return SigmaEstMax, 0
+	}
+
+	finalRangeMclks := v.timeoutMicrosecondsToMclks(v.finalRangeTimeoutUsec, v.finalRangeVcselPclks)
+	preRangeMclks := v.timeoutMicrosecondsToMclks(v.preRangeTimeoutUsec, v.preRangeVcselPclks)
+	vcselWidth := float32(3)
+	if v.finalRangeVcselPclks == 8 {
+		vcselWidth = 2
+	}
+	peakVcselDurationUs := vcselWidth * 2048 * float32(preRangeMclks+finalRangeMclks) *
+		PllPeriodPs / 1e6
+	vcselTotalEventsRtn := totalSignalMcps * peakVcselDurationUs
+	if vcselTotalEventsRtn < 1 {
+		vcselTotalEventsRtn = 1
+	}
+
+	sigmaEstimateP1 := float32(PulseEffectiveWidthCentiNs)
+	ambToSignalRatio := ambientMcps * 1000 / peakSignalKcps
+	if ambToSignalRatio > AmbToSignalRatioMax {
+		ambToSignalRatio = AmbToSignalRatioMax
+	}
+	sigmaEstimateP2 := ambToSignalRatio * AmbientEffectiveWidthCentiNs
+	sigmaEstimateP3 := 2 * sqrt32(vcselTotalEventsRtn*12)
+
+	pwMult := float32(1)
+	if valid {
+		deltaTPs := rangeMm * TofPerMmPs
+		xTalkCorrection := abs32((peakSignalKcps - xTalkKcps) / (peakSignalKcps + xTalkKcps))
+		pwMult = 1 + deltaTPs/VcselPulseWidthPs*(1-xTalkCorrection)
+		pwMult *= pwMult
+	}
+
+	sqr1 := pwMult * sigmaEstimateP1
+	sqr2 := sigmaEstimateP2
+	sqrtResultCentiNs := sqrt32(sqr1*sqr1 + sqr2*sqr2)
+	sigmaEstRtn := sqrtResultCentiNs / 100 / sigmaEstimateP3 * SpeedOfLightInAir / 10000
+	if sigmaEstRtn > SigmaEstRtnMax {
+		sigmaEstRtn = SigmaEstRtnMax
+	}
+
+	// "sigmaEstRef = 1mm * 25ms/final range integration time (inc pre-range)"
+	sigmaEstRef := sqrt32(DfltFinalRangeIntegrationTimeMs/integrationTimeMs) / 1000
+
+	sigmaMm = 1000 * sqrt32(sigmaEstRtn*sigmaEstRtn+sigmaEstRef*sigmaEstRef)
+	if sigmaMm > SigmaEstMax {
+		sigmaMm = SigmaEstMax
+	}
+
+	dmaxMm = v.dmaxEstimate(signalMcps, pwMult, sigmaEstimateP1, sigmaEstimateP2,
+		peakVcselDurationUs)
+	return sigmaMm, dmaxMm
+}
+
+// Estimate maximum detection distance in millimeters: the lowest one of distance,
+// where signal rate drops below signal rate limit, and distance, where sigma
+// exceeds sigma limit because of ambient light. Floating point adaptation
+// of VL53L0X_calc_dmax(), which use fixed point arithmetic.
+func (v *Vl53l0x) dmaxEstimate(correctedSignalMcps, pwMult, sigmaEstimateP1,
+	sigmaEstimateP2, peakVcselDurationUs float32) float32 {
+
+	const SigmaLimitMm = 18
+	const SignalLimitMcps = 0.25
+	const SigmaEstRef = 0.001
+	const AmbEffWidthSigmaEstNs = 6
+	const AmbEffWidthDMaxNs = 7
+
+	signalAt0mm := float32(dmaxCalRangeMm*dmaxCalRangeMm) * dmaxCalSignalRateMcps
+
+	var minSignalNeededP1 float32
+	if correctedSignalMcps > 0 {
+		// "apply a factored version of the speed of light"
+		minSignalNeededP1 = SignalLimitMcps / correctedSignalMcps * 3
+		minSignalNeededP1 *= minSignalNeededP1
+	}
+	minSignalNeededP2 := pwMult * sigmaEstimateP1
+	minSignalNeededP2 *= minSignalNeededP2
+	// "DMAX uses a different ambient width from sigma, so apply correction"
+	minSignalNeededP3 := sigmaEstimateP2 / AmbEffWidthSigmaEstNs * AmbEffWidthDMaxNs
+	minSignalNeededP3 *= minSignalNeededP3
+	sigmaLimit := float32(SigmaLimitMm) / 1000
+	minSignalNeededP4 := 4 * 12 * (sigmaLimit*sigmaLimit - SigmaEstRef*SigmaEstRef)
+
+	minSignalNeeded := (minSignalNeededP2 + minSignalNeededP3) / peakVcselDurationUs /
+		minSignalNeededP4 * minSignalNeededP1 / 1e6
+
+	// like in ST API, ambient DMax is zero, if signal needed can't be estimated
+	var dmaxAmbientMm float32
+	if minSignalNeeded > 0 {
+		dmaxAmbientMm = sqrt32(signalAt0mm / minSignalNeeded)
+	}
+	dmaxMm := sqrt32(signalAt0mm / SignalLimitMcps)
+	if dmaxAmbientMm < dmaxMm {
+		dmaxMm = dmaxAmbientMm
+	}
+	return dmaxMm
+}
+
+// Square root of float32 value.
+func sqrt32(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
+
+// Absolute value of float32 value.
+func abs32(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
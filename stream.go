@@ -0,0 +1,250 @@
+package vl53l0x
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	i2c "github.com/d2r2/go-i2c"
+)
+
+// ContinuousSample is a measurement result delivered by StreamContinuous.
+type ContinuousSample struct {
+	RangeData
+	// Number of measurements lost right before this one,
+	// because consumer didn't take them in time.
+	Dropped int
+}
+
+// StreamRetryPolicy define how StreamContinuous handle transient errors.
+type StreamRetryPolicy struct {
+	// Number of consecutive failures tolerated before stream is finished.
+	// Zero value means that stream is finished on first error.
+	MaxFailures int
+	// Delay before first retry, which doubles on each subsequent failure.
+	InitialBackoff time.Duration
+	// Upper limit of delay between retries; zero value means no limit.
+	MaxBackoff time.Duration
+	// Number of consecutive "device is gone" errors (ENODEV, ENXIO,
+	// EREMOTEIO or closed connection), after which sensor is treated
+	// as disconnected, and stream is finished with ErrDeviceDisconnected.
+	// Such errors are retried until limit is reached regardless
+	// of MaxFailures. Zero value means that first such error
+	// finishes the stream.
+	DisconnectThreshold int
+}
+
+// ErrDeviceDisconnected is matched by errors.Is for error, which finishes
+// StreamContinuous, when sensor doesn't respond anymore (see
+// StreamRetryPolicy.DisconnectThreshold).
+var ErrDeviceDisconnected = errors.New("device disconnected")
+
+// SetStreamRetryPolicy set how StreamContinuous handle transient errors,
+// like occasional bus glitches, and errors caused by disconnected sensor.
+func (v *Vl53l0x) SetStreamRetryPolicy(policy StreamRetryPolicy) {
+	v.streamRetryPolicy = policy
+}
+
+// StreamContinuous start continuous ranging measurements (see StartContinuous
+// for periodMs meaning) and deliver results to the channel until ctx is cancelled,
+// or unrecoverable error occurs (see SetStreamRetryPolicy). On exit continuous mode
+// is stopped, results channel is closed, and error (if any) is sent to errors channel,
+// which is closed afterwards.
+//
+// Measurement result is taken only on interrupt status transition to "ready",
+// so the same measurement is never delivered twice. When consumer is slower
+// than the sensor, pending result is replaced with the fresh one, and
+// ContinuousSample.Dropped reports how many results were lost.
+//
+// Don't communicate with the sensor via i2c until stream is finished.
+func (v *Vl53l0x) StreamContinuous(ctx context.Context, i2c *i2c.I2C,
+	periodMs uint32) (<-chan ContinuousSample, <-chan error, error) {
+
+	err := v.StartContinuous(i2c, periodMs)
+	if err != nil {
+		return nil, nil, err
+	}
+	samples := make(chan ContinuousSample, 1)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(samples)
+		err := v.streamContinuous(ctx, i2c, samples)
+		err2 := v.StopContinuous(i2c)
+		if err == nil {
+			err = err2
+		}
+		if err != nil {
+			v.errorf("Continuous measurement stream failed: %v", err)
+			errs <- err
+		}
+	}()
+	return samples, errs, nil
+}
+
+// RunContinuous start continuous ranging measurements (see StartContinuous
+// for periodMs meaning) and invoke cb for each measurement result, until ctx
+// is cancelled, or cb returns error, which is returned then. Continuous mode
+// is stopped on exit. Like StreamContinuous, delivers each measurement only once.
+//
+// Don't communicate with the sensor via i2c from cb.
+func (v *Vl53l0x) RunContinuous(ctx context.Context, i2c *i2c.I2C, periodMs uint32,
+	cb func(RangeData) error) error {
+
+	err := v.StartContinuous(i2c, periodMs)
+	if err != nil {
+		return err
+	}
+	err = v.runContinuous(ctx, i2c, cb)
+	err2 := v.StopContinuous(i2c)
+	if err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Read continuous measurement results and pass them to cb
+// until ctx is cancelled, or error occurs.
+func (v *Vl53l0x) runContinuous(ctx context.Context, i2c *i2c.I2C,
+	cb func(RangeData) error) error {
+
+	for {
+		data, err := v.readNextContinuous(ctx, i2c)
+		if err != nil {
+			return err
+		} else if data == nil {
+			// cancelled
+			return nil
+		}
+		err = cb(*data)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Read continuous measurement results and deliver them to samples channel
+// until ctx is cancelled, or error occurs.
+func (v *Vl53l0x) streamContinuous(ctx context.Context, i2c *i2c.I2C,
+	samples chan ContinuousSample) error {
+
+	var dropped int
+	var failures int
+	var disconnects int
+	backoff := v.streamRetryPolicy.InitialBackoff
+	for {
+		data, err := v.readNextContinuous(ctx, i2c)
+		if err != nil {
+			if errors.Is(err, ErrClosed) {
+				return err
+			} else if isDisconnectError(err) {
+				disconnects++
+				if disconnects >= v.streamRetryPolicy.DisconnectThreshold {
+					return fmt.Errorf("%w: %v", ErrDeviceDisconnected, err)
+				}
+			} else {
+				disconnects = 0
+				failures++
+				if failures > v.streamRetryPolicy.MaxFailures {
+					return err
+				}
+			}
+			v.debugf("Continuous measurement failed (%d in a row), retry in %v: %v",
+				failures+disconnects, backoff, err)
+			// result might be read, but interrupt isn't cleared,
+			// so don't take it once again
+			v.resultArmed = false
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil
+			}
+			backoff *= 2
+			if maxBackoff := v.streamRetryPolicy.MaxBackoff; maxBackoff > 0 && backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		} else if data == nil {
+			// cancelled
+			return nil
+		}
+		failures = 0
+		disconnects = 0
+		backoff = v.streamRetryPolicy.InitialBackoff
+
+		sample := ContinuousSample{RangeData: *data}
+		select {
+		case prev := <-samples:
+			// consumer didn't take previous result, so replace it
+			dropped += prev.Dropped + 1
+			v.debugf("Continuous measurement dropped, %d lost in a row", dropped)
+		default:
+		}
+		sample.Dropped = dropped
+		select {
+		case samples <- sample:
+			dropped = 0
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Wait for next continuous measurement result (see isNewResultReady)
+// and read it. Return nil result, if ctx is cancelled.
+func (v *Vl53l0x) readNextContinuous(ctx context.Context, i2c *i2c.I2C) (*RangeData, error) {
+
+	st := v.startTimeout()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		default:
+		}
+		u8, err := v.readRegU8(i2c, RESULT_INTERRUPT_STATUS)
+		if err != nil {
+			return nil, err
+		}
+		ready, err := v.isNewResultReady(i2c, u8)
+		if err != nil {
+			return nil, err
+		} else if ready {
+			break
+		}
+		if v.checkTimeoutExpiredAfter(st, v.measurementTimeout()) {
+			v.metrics.addTimeout()
+			return nil, &timeoutError{reg: RESULT_INTERRUPT_STATUS, value: u8}
+		}
+	}
+
+	data, err := v.readResult(i2c)
+	if err != nil {
+		return nil, err
+	}
+	err = v.clearResultInterrupt(i2c)
+	if err != nil {
+		return nil, err
+	}
+	v.metrics.addMeasurement(data.RangeMm)
+
+	return data, nil
+}
+
+// Check that error means device or bus is gone,
+// so it makes no sense to retry operation.
+func isFatalBusError(err error) bool {
+	return errors.Is(err, syscall.ENODEV) || errors.Is(err, os.ErrClosed)
+}
+
+// Check that error might mean disconnected sensor: fatal bus error
+// (see isFatalBusError), ENXIO ("no such device or address", device
+// doesn't acknowledge its address) or EREMOTEIO (device doesn't acknowledge
+// transfer). Latter ones are produced by marginal bus as well, so they
+// indicate disconnection only when repeated.
+func isDisconnectError(err error) bool {
+	return isFatalBusError(err) || errors.Is(err, syscall.ENXIO) ||
+		errors.Is(err, syscall.EREMOTEIO)
+}
@@ -0,0 +1,103 @@
+package vl53l0x
+
+// Trend is a direction of target movement detected by TrendDetector.
+type Trend int
+
+const (
+	// TrendStable means target doesn't move noticeably.
+	TrendStable Trend = iota
+	// TrendApproaching means target moves toward the sensor.
+	TrendApproaching
+	// TrendReceding means target moves away from the sensor.
+	TrendReceding
+)
+
+// String implement Stringer interface.
+func (v Trend) String() string {
+	switch v {
+	case TrendStable:
+		return "Stable"
+	case TrendApproaching:
+		return "Approaching"
+	case TrendReceding:
+		return "Receding"
+	default:
+		return "<unknown>"
+	}
+}
+
+// TrendDetector detects direction of target movement from consecutive
+// distance readings. Readings are smoothed by exponential moving average,
+// and so is their derivative (distance change per reading). Trend changes
+// from stable, when derivative exceeds deadband, and returns back to stable
+// only when derivative falls below half of deadband (hysteresis), so jitter
+// doesn't flip the trend.
+type TrendDetector struct {
+	alpha      float64
+	deadbandMm float64
+	distance   float64
+	slope      float64
+	trend      Trend
+	started    bool
+}
+
+// NewTrendDetector creates trend detector. Alpha (0..1] is a smoothing
+// factor: the less it is, the more smoothing is applied, and the slower
+// detector reacts. DeadbandMm is a minimum smoothed distance change
+// per reading in millimeters, treated as movement.
+func NewTrendDetector(alpha float64, deadbandMm float64) *TrendDetector {
+	if alpha <= 0 || alpha > 1 {
+		alpha = 1
+	}
+	v := &TrendDetector{alpha: alpha, deadbandMm: deadbandMm}
+	return v
+}
+
+// Update feed next distance reading in millimeters to the detector
+// and returns detected trend. Out of range readings (see MaxRangeMm)
+// are ignored, so current trend is returned for them.
+func (v *TrendDetector) Update(mm uint16) Trend {
+	if mm >= MaxRangeMm {
+		return v.trend
+	}
+	if !v.started {
+		v.distance = float64(mm)
+		v.started = true
+		return v.trend
+	}
+	prev := v.distance
+	v.distance += v.alpha * (float64(mm) - v.distance)
+	v.slope += v.alpha * (v.distance - prev - v.slope)
+
+	switch v.trend {
+	case TrendApproaching:
+		if v.slope > -v.deadbandMm/2 {
+			v.trend = TrendStable
+		}
+	case TrendReceding:
+		if v.slope < v.deadbandMm/2 {
+			v.trend = TrendStable
+		}
+	}
+	if v.trend == TrendStable {
+		if v.slope < -v.deadbandMm {
+			v.trend = TrendApproaching
+		} else if v.slope > v.deadbandMm {
+			v.trend = TrendReceding
+		}
+	}
+	return v.trend
+}
+
+// Trend returns trend detected by last Update.
+func (v *TrendDetector) Trend() Trend {
+	return v.trend
+}
+
+// Reset drop detector state, so next reading starts detection anew.
+func (v *TrendDetector) Reset() {
+	v.distance = 0
+	v.slope = 0
+	v.trend = TrendStable
+	v.started = false
+}
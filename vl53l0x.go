@@ -37,9 +37,13 @@ package vl53l0x
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
 	"time"
 
 	i2c "github.com/d2r2/go-i2c"
+	logger "github.com/d2r2/go-logger"
 	"github.com/davecgh/go-spew/spew"
 )
 
@@ -208,101 +212,352 @@ type Vl53l0x struct {
 	measurementTimingBudgetUsec uint32
 	// default timeout value
 	ioTimeout time.Duration
+	// sensor name used to distinguish log messages
+	// of multiple sensors
+	label string
+	// StreamContinuous transient errors handling
+	streamRetryPolicy StreamRetryPolicy
+	// Init read-modify-write transient errors handling
+	readRetryPolicy ReadRetryPolicy
+	// continuous measurement mode is active
+	continuous bool
+	// allow timing budget lower than recommended minimum
+	allowSubMinimumBudget bool
+	// final range timeout in microseconds, which is
+	// a remainder of timing budget set last time
+	finalRangeTimeoutUsec uint32
+	// range ignore threshold check settings
+	rangeIgnoreEnabled       bool
+	rangeIgnoreThresholdMcps float32
+	// operation counters; could be nil
+	metrics *Metrics
+	// reference calibration timeout; if zero, ioTimeout is used
+	calibrationTimeout time.Duration
+	// RESULT_RANGE_STATUS value of last measurement result read,
+	// and limit checks failed by the library
+	lastRangeStatus     byte
+	lastRangeIgnoreFail bool
+	lastSigmaFail       bool
+	// delay between polls while sensor reboots
+	resetPollInterval time.Duration
+	// sensor log output verbosity, if set
+	logLevel    logger.LogLevel
+	logLevelSet bool
+	// stop variable is written to internal register
+	// and wasn't overwritten since then
+	stopVariableWritten bool
+	// final range signal rate limit register value
+	// saved, when limit check is disabled
+	disabledSignalRateLimit uint16
+	// sequence step timings set last time,
+	// required for sigma estimate
+	preRangeTimeoutUsec  uint32
+	preRangeVcselPclks   uint16
+	finalRangeVcselPclks uint16
+	// crosstalk compensation rate per SPAD in MCPS
+	xTalkCompensationRateMcps float32
+	// sigma limit check settings
+	sigmaCheckEnabled bool
+	sigmaLimitMm      float32
+	// Init completed successfully
+	initialized bool
+	// reference SPAD info read by Init; nil if not read yet
+	spadInfo *SpadInfo
+	// return sensor to idle state on measurement timeout
+	recoverOnTimeout bool
+	// options used by Init last time
+	initOptions InitOptions
+	// number of measurement results taken
+	measurementSeq uint32
+	// interrupt is observed cleared since last measurement result
+	// taken, so next "ready" state means new measurement
+	resultArmed bool
+	// called when single-shot measurement is triggered; could be nil
+	onMeasurementStart func(time.Time)
+	// linearity corrective gain in 1/1000 units; zero means default
+	linearityCorrectiveGain uint16
+	// reject inter-measurement period shorter than timing budget
+	enforceMinimumPeriod bool
+	// derive measurement timeout from timing budget
+	autoTimeout bool
+	// inter-measurement period of active continuous timed mode
+	continuousPeriodMs uint32
+	// time of last reference calibration; zero, if not calibrated
+	refCalibratedAt time.Time
+	// time after which reference calibration is considered outdated
+	recalibrationInterval time.Duration
+	// non-zero, once Close is called, and sensor isn't initialized
+	// since then; accessed atomically, since Close might overlap
+	// with measurements running in another goroutine
+	closed uint32
+	// CheckModelID probes 16-bit model identifier of sibling sensors
+	probeSiblingModels bool
+	// drive sensor XSHUT pin; could be nil
+	setXShut func(high bool) error
+	// sensor is put to hardware standby by ReadRangeSingleLowPower
+	hardwareStandby bool
+	// sensor settings to restore on wake up from hardware standby
+	standbySettings *standbySettings
 }
 
 // NewVl53l0x creates sensor instance.
 func NewVl53l0x() *Vl53l0x {
-	v := &Vl53l0x{}
+	v := &Vl53l0x{resetPollInterval: defaultResetPollInterval,
+		recalibrationInterval: defaultRecalibrationInterval}
 	return v
 }
 
+// Default delay between polls while sensor reboots.
+const defaultResetPollInterval = time.Millisecond
+
+// SetResetPollInterval set delay between sensor polls, while waiting for
+// sensor reboot in Reset and WaitForBoot. Sensor doesn't respond during reboot, so polling
+// without delay floods I2C-bus with failing reads, which might disturb
+// other devices on the bus. Zero value means no delay.
+func (v *Vl53l0x) SetResetPollInterval(interval time.Duration) {
+	v.resetPollInterval = interval
+}
+
+// Sensor is an interface implemented by Vl53l0x, which
+// could be used to substitute sensor with fake one in tests.
+type Sensor interface {
+	Reset(i2c *i2c.I2C) error
+	Init(i2c *i2c.I2C) error
+	Config(i2c *i2c.I2C, rng RangeSpec, speed SpeedAccuracySpec) error
+	GetProductMinorRevision(i2c *i2c.I2C) (byte, error)
+	SetAddress(i2cRef **i2c.I2C, newAddr byte) error
+	SetSignalRateLimit(i2c *i2c.I2C, limitMcps float32) error
+	GetSignalRateLimit(i2c *i2c.I2C) (float32, error)
+	SetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType, periodPclks uint8) error
+	SetMeasurementTimingBudget(i2c *i2c.I2C, budgetUsec uint32) error
+	QueryTimingBudget(i2c *i2c.I2C) (uint32, error)
+	StartContinuous(i2c *i2c.I2C, periodMs uint32) error
+	StopContinuous(i2c *i2c.I2C) error
+	ReadRangeContinuousMillimeters(i2c *i2c.I2C) (uint16, error)
+	ReadRangeSingleMillimeters(i2c *i2c.I2C) (uint16, error)
+}
+
+// Static check that Vl53l0x implements Sensor interface.
+var _ Sensor = (*Vl53l0x)(nil)
+
+// NewSensor creates sensor instance and returns it as Sensor interface.
+func NewSensor() Sensor {
+	return NewVl53l0x()
+}
+
+// SetMetrics attach operation counters to the sensor; nil detach them.
+// The same Metrics could be shared by multiple sensors.
+func (v *Vl53l0x) SetMetrics(metrics *Metrics) {
+	v.metrics = metrics
+}
+
+// SetLabel set sensor name, which prefix log messages of this sensor.
+// Useful to distinguish log output, when multiple sensors are used.
+func (v *Vl53l0x) SetLabel(label string) {
+	v.label = label
+}
+
+// Label returns sensor name specified by SetLabel.
+func (v *Vl53l0x) Label() string {
+	return v.label
+}
+
 // Config configure sensor expected distance range and time to make a measurement.
+// Sensor should be initialized first (see Init and IsInitialized),
+// otherwise error is returned.
 func (v *Vl53l0x) Config(i2c *i2c.I2C, rng RangeSpec, speed SpeedAccuracySpec) error {
 
-	lg.Debug("Start config")
+	v.debug("Start config")
 
-	switch rng {
-	case RegularRange:
-		// default is 0.25 MCPS
-		err := v.SetSignalRateLimit(i2c, 0.25)
+	if !v.initialized {
+		return errConfigBeforeInit
+	}
+
+	if limitMcps, prePclks, finalPclks, ok := rangeSpecSettings(rng); ok {
+		err := v.applyRangeSettings(i2c, limitMcps, prePclks, finalPclks)
 		if err != nil {
 			return err
 		}
-		// defaults are 14 and 10 PCLKs)
-		err = v.SetVcselPulsePeriod(i2c, VcselPeriodPreRange, 14)
+	}
+
+	if budgetUsec, ok := speedAccuracySpecBudget(speed); ok {
+		err := v.SetMeasurementTimingBudget(i2c, budgetUsec)
 		if err != nil {
 			return err
 		}
-		err = v.SetVcselPulsePeriod(i2c, VcselPeriodFinalRange, 10)
+	}
+
+	v.debug("End config")
+
+	return nil
+}
+
+// RestoreDefaults return sensor settings changed by configuration methods
+// to the state left by Init, without reset and full initialization: sequence
+// config set by Init (see InitOptions), 0.25 MCPS signal rate limit,
+// 14 and 10 PCLKs pre-range and final range VCSEL pulse periods, and about
+// 33 ms timing budget, i.e. RegularRange and RegularAccuracy configuration.
+// Calibration settings (offset, crosstalk) are left intact.
+func (v *Vl53l0x) RestoreDefaults(i2c *i2c.I2C) error {
+
+	v.debug("Restore defaults")
+
+	if !v.initialized {
+		return errConfigBeforeInit
+	}
+	// timing budget is re-applied by Config
+	err := v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, v.initOptions.sequenceConfig())
+	if err != nil {
+		return err
+	}
+	return v.Config(i2c, RegularRange, RegularAccuracy)
+}
+
+// Config relies on tuning and calibration done by Init.
+var errConfigBeforeInit = errors.New("Config called before Init")
+
+// ConfigFast configure sensor the same way as Config does, but skip
+// steps which don't change anything: VCSEL pulse periods (followed by
+// phase calibration) are applied only if they differ from current ones,
+// and measurement timing budget only if it differs from the last one set.
+// Useful for frequent switching between presets.
+func (v *Vl53l0x) ConfigFast(i2c *i2c.I2C, rng RangeSpec, speed SpeedAccuracySpec) error {
+
+	v.debug("Start fast config")
+
+	if !v.initialized {
+		return errConfigBeforeInit
+	}
+
+	if limitMcps, prePclks, finalPclks, ok := rangeSpecSettings(rng); ok {
+		err := v.SetSignalRateLimit(i2c, limitMcps)
 		if err != nil {
 			return err
 		}
-	case LongRange:
-		// lower the return signal rate limit (default is 0.25 MCPS)
-		err := v.SetSignalRateLimit(i2c, 0.1)
+		curPrePclks, err := v.getVcselPulsePeriod(i2c, VcselPeriodPreRange)
 		if err != nil {
 			return err
 		}
-		// increase laser pulse periods (defaults are 14 and 10 PCLKs)
-		err = v.SetVcselPulsePeriod(i2c, VcselPeriodPreRange, 18)
+		curFinalPclks, err := v.getVcselPulsePeriod(i2c, VcselPeriodFinalRange)
 		if err != nil {
 			return err
 		}
-		err = v.SetVcselPulsePeriod(i2c, VcselPeriodFinalRange, 14)
+		if curPrePclks != prePclks || curFinalPclks != finalPclks {
+			err = v.SetVcselPulsePeriods(i2c, prePclks, finalPclks)
+			if err != nil {
+				return err
+			}
+		} else {
+			v.debug("VCSEL pulse periods are unchanged, skip phase calibration")
+		}
+	}
+
+	if budgetUsec, ok := speedAccuracySpecBudget(speed); ok &&
+		budgetUsec != v.measurementTimingBudgetUsec {
+		err := v.SetMeasurementTimingBudget(i2c, budgetUsec)
 		if err != nil {
 			return err
 		}
 	}
 
+	v.debug("End fast config")
+
+	return nil
+}
+
+// PlanConfig returns register writes, which Config would perform to configure
+// sensor with the same parameters, without any communication with sensor.
+// 16-bit register is represented by two pairs (MSB first). Note, that plan
+// is not complete: Config also rewrite sequence step timeouts (including
+// measurement timing budget) and perform phase calibration, which depend
+// on actual sensor state; such steps are omitted here.
+func (v *Vl53l0x) PlanConfig(rng RangeSpec, speed SpeedAccuracySpec) ([]RegBytePair, error) {
+	var plan []RegBytePair
+
+	if limitMcps, prePclks, finalPclks, ok := rangeSpecSettings(rng); ok {
+		u16, err := v.encodeSignalRateLimit(limitMcps)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan,
+			RegBytePair{Reg: FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT, Value: byte(u16 >> 8)},
+			RegBytePair{Reg: FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT + 1, Value: byte(u16)})
+		for _, item := range []struct {
+			tpe         VcselPeriodType
+			periodPclks uint8
+		}{{VcselPeriodPreRange, prePclks}, {VcselPeriodFinalRange, finalPclks}} {
+			settings, err := v.planVcselPeriodSettings(item.tpe, item.periodPclks)
+			if err != nil {
+				return nil, err
+			}
+			plan = append(plan, settings...)
+		}
+	}
+
+	return plan, nil
+}
+
+// Return signal rate limit and VCSEL pulse periods corresponding to RangeSpec.
+func rangeSpecSettings(rng RangeSpec) (limitMcps float32, prePclks, finalPclks uint8, ok bool) {
+	switch rng {
+	case RegularRange:
+		// default is 0.25 MCPS;
+		// defaults are 14 and 10 PCLKs
+		return 0.25, 14, 10, true
+	case LongRange:
+		// lower the return signal rate limit (default is 0.25 MCPS);
+		// increase laser pulse periods (defaults are 14 and 10 PCLKs)
+		return 0.1, 18, 14, true
+	default:
+		return 0, 0, 0, false
+	}
+}
+
+// Return measurement timing budget in microseconds corresponding to SpeedAccuracySpec.
+func speedAccuracySpecBudget(speed SpeedAccuracySpec) (budgetUsec uint32, ok bool) {
 	switch speed {
 	case HighSpeed:
 		// reduce timing budget to 20 ms (default is about 33 ms)
-		err := v.SetMeasurementTimingBudget(i2c, 20000)
-		if err != nil {
-			return err
-		}
+		return 20000, true
 	case RegularAccuracy:
 		// default is about 33 ms
-		err := v.SetMeasurementTimingBudget(i2c, 33000)
-		if err != nil {
-			return err
-		}
+		return 33000, true
 	case GoodAccuracy:
 		// increase timing budget to 66 ms
-		err := v.SetMeasurementTimingBudget(i2c, 66000)
-		if err != nil {
-			return err
-		}
+		return 66000, true
 	case HighAccuracy:
 		// increase timing budget to 100 ms
-		err := v.SetMeasurementTimingBudget(i2c, 100000)
-		if err != nil {
-			return err
-		}
+		return 100000, true
 	case HighestAccuracy:
 		// increase timing budget to 200 ms
-		err := v.SetMeasurementTimingBudget(i2c, 200000)
-		if err != nil {
-			return err
-		}
+		return 200000, true
+	default:
+		return 0, false
 	}
+}
 
-	lg.Debug("End config")
-
-	return nil
+// Apply signal rate limit and VCSEL pulse periods, which define expected distance range.
+func (v *Vl53l0x) applyRangeSettings(i2c *i2c.I2C, limitMcps float32, prePclks, finalPclks uint8) error {
+	err := v.SetSignalRateLimit(i2c, limitMcps)
+	if err != nil {
+		return err
+	}
+	return v.SetVcselPulsePeriods(i2c, prePclks, finalPclks)
 }
 
 // Reset soft-reset the sensor.
 // Based on VL53L0X_ResetDevice().
 func (v *Vl53l0x) Reset(i2c *i2c.I2C) error {
+	v.forgetState()
+	v.spadInfo = nil
 	// Set reset bit
-	lg.Debug("Set reset bit")
+	v.debug("Set reset bit")
 	err := v.writeRegU8(i2c, SOFT_RESET_GO2_SOFT_RESET_N, 0x00)
 	if err != nil {
 		return err
 	}
 	// Wait for some time
-	err = v.waitUntilOrTimeout(i2c, IDENTIFICATION_MODEL_ID,
+	err = v.waitUntilOrTimeoutEvery(i2c, IDENTIFICATION_MODEL_ID, v.ioTimeout, v.resetPollInterval,
 		func(checkReg byte, err error) (bool, error) {
 			return checkReg == 0, err
 		})
@@ -310,24 +565,30 @@ func (v *Vl53l0x) Reset(i2c *i2c.I2C) error {
 		return err
 	}
 	// Release reset
-	lg.Debug("Release reset bit")
+	v.debug("Release reset bit")
 	err = v.writeRegU8(i2c, SOFT_RESET_GO2_SOFT_RESET_N, 0x01)
 	if err != nil {
 		return err
 	}
 	// Wait for some time
-	err = v.waitUntilOrTimeout(i2c, IDENTIFICATION_MODEL_ID,
+	return v.WaitForBoot(i2c, v.ioTimeout)
+}
+
+// WaitForBoot wait until sensor boots after reset or power up (XSHUT pin
+// release), polling model identifier until sensor responds with expected
+// value or timeout expires. Zero timeout means wait forever.
+func (v *Vl53l0x) WaitForBoot(i2c *i2c.I2C, timeout time.Duration) error {
+
+	v.debug("Wait for sensor boot")
+
+	return v.waitUntilOrTimeoutEvery(i2c, IDENTIFICATION_MODEL_ID, timeout, v.resetPollInterval,
 		func(checkReg byte, err error) (bool, error) {
 			// Skip error like "read /dev/i2c-x: no such device or address"
 			// for a while, because sensor in reboot has temporary
 			// no connection to I2C-bus. So, that is why we are
 			// returning nil instead of err, suppressing this.
-			return checkReg != 0, nil
+			return err == nil && checkReg == modelID, nil
 		})
-	if err != nil {
-		return err
-	}
-	return nil
 }
 
 // GetProductMinorRevision takes revision from sensor hardware.
@@ -341,13 +602,41 @@ func (v *Vl53l0x) GetProductMinorRevision(i2c *i2c.I2C) (byte, error) {
 }
 
 // SetAddress change default address of sensor and reopen I2C-connection.
+// Valid 7-bit addresses are 0x08..0x77: addresses outside of this range
+// are reserved by I2C-bus specification, so error is returned for them.
+// New connection is verified by reading sensor model identifier; if sensor
+// doesn't respond at new address, new connection is closed, and *i2cRef keeps
+// old connection, so operation could be retried.
 func (v *Vl53l0x) SetAddress(i2cRef **i2c.I2C, newAddr byte) error {
-	err := v.writeRegU8(*i2cRef, I2C_SLAVE_DEVICE_ADDRESS, newAddr&0x7F)
+	if newAddr < 0x08 || newAddr > 0x77 {
+		return errors.New(spew.Sprintf("address 0x%x is out of valid range 0x08..0x77", newAddr))
+	}
+	oldConn := *i2cRef
+	err := v.writeRegU8(oldConn, I2C_SLAVE_DEVICE_ADDRESS, newAddr&0x7F)
 	if err != nil {
 		return err
 	}
-	*i2cRef, err = i2c.NewI2C(newAddr, (*i2cRef).GetBus())
-	return err
+	newConn, err := i2c.NewI2C(newAddr, oldConn.GetBus())
+	if err != nil {
+		return err
+	}
+	id, err := v.readRegU8(newConn, IDENTIFICATION_MODEL_ID)
+	if err == nil && id == modelID {
+		*i2cRef = newConn
+		return nil
+	}
+	newConn.Close()
+
+	v.debugf("Sensor doesn't respond at new address 0x%x, check old address 0x%x",
+		newAddr, oldConn.GetAddr())
+
+	id, err2 := v.readRegU8(oldConn, IDENTIFICATION_MODEL_ID)
+	if err2 == nil && id == modelID {
+		return errors.New(spew.Sprintf("address change to 0x%x failed, "+
+			"sensor still responds at old address 0x%x", newAddr, oldConn.GetAddr()))
+	}
+	return errors.New(spew.Sprintf("address change to 0x%x failed, sensor responds "+
+		"neither at new address, nor at old address 0x%x", newAddr, oldConn.GetAddr()))
 }
 
 // Init initialize sensor using sequence based on VL53L0X_DataInit(),
@@ -357,8 +646,45 @@ func (v *Vl53l0x) SetAddress(i2cRef **i2c.I2C, newAddr byte) error {
 // is performed by ST on the bare modules; it seems like that should work well
 // enough unless a cover glass is added.
 func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
+	return v.InitWithOptions(i2c, InitOptions{})
+}
+
+// InitOptions define optional sensor settings applied by InitWithOptions.
+type InitOptions struct {
+	// Keep MSRC (minimum signal rate check) sequence step enabled,
+	// instead of disabling it by default.
+	EnableMSRC bool
+	// Keep TCC (target centre check) sequence step enabled,
+	// instead of disabling it by default.
+	EnableTCC bool
+}
+
+// Sequence config with final range, pre-range and DSS steps enabled.
+const defaultSequenceConfig = 0xE8
 
+// Build SYSTEM_SEQUENCE_CONFIG value according to options.
+func (v InitOptions) sequenceConfig() byte {
+	config := byte(defaultSequenceConfig)
+	if v.EnableMSRC {
+		config |= 0x04
+	}
+	if v.EnableTCC {
+		config |= 0x10
+	}
+	return config
+}
+
+// InitWithOptions initialize sensor like Init does, but apply opts
+// while initializing, so timing budget is calculated for sequence steps
+// enabled. Options are kept and reused by EnsureInitialized.
+func (v *Vl53l0x) InitWithOptions(i2c *i2c.I2C, opts InitOptions) error {
+
+	v.initOptions = opts
 	v.setTimeout(time.Millisecond * 1000)
+	v.initialized = false
+	v.stopVariableWritten = false
+	// sensor is opened again after Close
+	v.setClosed(false)
 
 	// VL53L0X_DataInit() begin
 
@@ -368,33 +694,24 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 		return err
 	}
 
-	err = v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x80, Value: 0x01},
-		{Reg: 0xFF, Value: 0x01},
-		{Reg: 0x00, Value: 0x00},
-	}...)
-	if err != nil {
-		return err
-	}
-	v.stopVariable, err = v.readRegU8(i2c, 0x91)
-	if err != nil {
-		return err
-	}
-	err = v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x00, Value: 0x01},
-		{Reg: 0xFF, Value: 0x00},
-		{Reg: 0x80, Value: 0x00},
-	}...)
+	err = v.withPowerForce(i2c, func() error {
+		return v.withPage(i2c, 0x01, func() error {
+			var err error
+			v.stopVariable, err = v.readRegU8(i2c, 0x91)
+			return err
+		})
+	})
 	if err != nil {
 		return err
 	}
 
 	// disable SIGNAL_RATE_MSRC (bit 1) and SIGNAL_RATE_PRE_RANGE (bit 4) limit checks
-	u8, err := v.readRegU8(i2c, MSRC_CONFIG_CONTROL)
+	u8, err := v.readRegU8Retry(i2c, MSRC_CONFIG_CONTROL)
 	if err != nil {
 		return err
 	}
-	err = v.writeRegU8(i2c, MSRC_CONFIG_CONTROL, u8|0x12)
+	err = v.writeRegU8(i2c, MSRC_CONFIG_CONTROL,
+		u8|msrcControlDisableMsrc|msrcControlDisablePreRange)
 	if err != nil {
 		return err
 	}
@@ -433,7 +750,7 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 	err = v.writeRegValues(i2c, []RegBytePair{
 		{Reg: 0xFF, Value: 0x01},
 		{Reg: DYNAMIC_SPAD_REF_EN_START_OFFSET, Value: 0x00},
-		{Reg: DYNAMIC_SPAD_NUM_REQUESTED_REF_SPAD, Value: 0x2C},
+		{Reg: DYNAMIC_SPAD_NUM_REQUESTED_REF_SPAD, Value: MaxRefSpadCount},
 		{Reg: 0xFF, Value: 0x00},
 		{Reg: GLOBAL_CONFIG_REF_EN_START_SELECT, Value: 0xB4},
 	}...)
@@ -449,7 +766,7 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 	var spadsEnabled byte
 
 	var i byte
-	for i = 0; i < 48; i++ {
+	for i = 0; i < RefSpadArraySize; i++ {
 		if i < firstSpadToEnable || spadsEnabled == spadInfo.Count {
 			// This bit is lower than the first one that should be enabled, or
 			// (reference_spad_count) bits have already been enabled, so zero this bit
@@ -642,7 +959,7 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 	if err != nil {
 		return err
 	}
-	u8, err = v.readRegU8(i2c, GPIO_HV_MUX_ACTIVE_HIGH)
+	u8, err = v.readRegU8Retry(i2c, GPIO_HV_MUX_ACTIVE_HIGH)
 	if err != nil {
 		return err
 	}
@@ -662,24 +979,19 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 	}
 	v.measurementTimingBudgetUsec = u32
 
-	// "Disable MSRC and TCC by default"
+	// "Disable MSRC and TCC by default", unless options say otherwise
 	// MSRC = Minimum Signal Rate Check
 	// TCC = Target CentreCheck
 	// -- VL53L0X_SetSequenceStepEnable() begin
+	// "Recalculate timing budget"
 
-	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, 0xE8)
+	err = v.SetSequenceConfig(i2c, opts.sequenceConfig())
 	if err != nil {
 		return err
 	}
 
 	// -- VL53L0X_SetSequenceStepEnable() end
 
-	// "Recalculate timing budget"
-	err = v.SetMeasurementTimingBudget(i2c, v.measurementTimingBudgetUsec)
-	if err != nil {
-		return err
-	}
-
 	// VL53L0X_StaticInit() end
 
 	// VL53L0X_PerformRefCalibration() begin (VL53L0X_perform_ref_calibration())
@@ -711,16 +1023,42 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 	// -- VL53L0X_perform_phase_calibration() end
 
 	// "restore the previous Sequence Config"
-	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, 0xE8)
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, opts.sequenceConfig())
 	if err != nil {
 		return err
 	}
 
 	// VL53L0X_PerformRefCalibration() end
 
+	v.initialized = true
+	v.refCalibratedAt = time.Now()
+
 	return nil
 }
 
+// Drop sensor state, which is lost on sensor reset or power loss.
+func (v *Vl53l0x) forgetState() {
+	v.initialized = false
+	v.refCalibratedAt = time.Time{}
+	v.stopVariableWritten = false
+	v.continuous = false
+}
+
+// IsInitialized returns true, if Init completed successfully,
+// and sensor wasn't reset since then.
+func (v *Vl53l0x) IsInitialized() bool {
+	return v.initialized
+}
+
+// EnsureInitialized initialize sensor via Init (with options
+// passed to InitWithOptions last time), unless it is already initialized.
+func (v *Vl53l0x) EnsureInitialized(i2c *i2c.I2C) error {
+	if v.initialized {
+		return nil
+	}
+	return v.InitWithOptions(i2c, v.initOptions)
+}
+
 // SetSignalRateLimit set the return signal rate limit check value in units of MCPS
 // (mega counts per second). "This represents the amplitude of the signal reflected
 // from the target and detected by the device"; setting this limit presumably determines
@@ -730,25 +1068,101 @@ func (v *Vl53l0x) Init(i2c *i2c.I2C) error {
 // unwanted reflections from objects other than the intended target.
 // Defaults to 0.25 MCPS as initialized by the ST API and this library.
 func (v *Vl53l0x) SetSignalRateLimit(i2c *i2c.I2C, limitMcps float32) error {
-	if limitMcps < 0 || limitMcps > 511.99 {
-		return errors.New("out of MCPS range")
+	u16, err := v.encodeSignalRateLimit(limitMcps)
+	if err != nil {
+		return err
 	}
-	// Q9.7 fixed point format (9 integer bits, 7 fractional bits)
-	err := v.writeRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT,
-		uint16(limitMcps*(1<<7)))
+	err = v.writeRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT, u16)
 	return err
 }
 
+// Encode signal rate limit register value from limit in MCPS.
+func (v *Vl53l0x) encodeSignalRateLimit(limitMcps float32) (uint16, error) {
+	if limitMcps < 0 || limitMcps > 511.99 {
+		return 0, errors.New("out of MCPS range")
+	}
+	return MCPSToFixed(limitMcps), nil
+}
+
+// MCPSToFixed convert rate in MCPS to Q9.7 fixed point format (9 integer bits,
+// 7 fractional bits), used by signal rate limit and result rate registers.
+// Value is rounded to nearest 1/128 MCPS, and clamped to 0..511.992 MCPS range.
+func MCPSToFixed(f float32) uint16 {
+	if f <= 0 {
+		return 0
+	}
+	fixed := f*(1<<7) + 0.5
+	if fixed >= math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(fixed)
+}
+
+// FixedToMCPS convert rate in Q9.7 fixed point format (see MCPSToFixed) to MCPS.
+func FixedToMCPS(u uint16) float32 {
+	return float32(u) / (1 << 7)
+}
+
+// SetSignalRateLimitChecked set the return signal rate limit the same way
+// as SetSignalRateLimit does, then read value back to verify that write
+// took effect. Error returned if value read differs from requested one
+// more than Q9.7 fixed point quantization step (1/128 MCPS).
+// Use it on unreliable bus, where lost write could silently leave
+// the sensor with default limit.
+func (v *Vl53l0x) SetSignalRateLimitChecked(i2c *i2c.I2C, limitMcps float32) error {
+	err := v.SetSignalRateLimit(i2c, limitMcps)
+	if err != nil {
+		return err
+	}
+	limit, err := v.GetSignalRateLimit(i2c)
+	if err != nil {
+		return err
+	}
+	const quantum = 1.0 / (1 << 7)
+	if diff := limit - limitMcps; diff >= quantum || diff <= -quantum {
+		return errors.New(spew.Sprintf("signal rate limit verification failed: "+
+			"requested %v MCPS, read back %v MCPS", limitMcps, limit))
+	}
+	return nil
+}
+
 // GetSignalRateLimit gets the return signal rate limit check value in MCPS.
 func (v *Vl53l0x) GetSignalRateLimit(i2c *i2c.I2C) (float32, error) {
 	u16, err := v.readRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT)
 	if err != nil {
 		return 0, err
 	}
-	limit := float32(u16) / (1 << 7)
+	limit := FixedToMCPS(u16)
 	return limit, nil
 }
 
+// SetRangeIgnoreThreshold enable or disable range ignore threshold check,
+// which suppress spurious short distance readings caused by crosstalk
+// (cover glass reflections) in long range mode. When return signal rate
+// per SPAD is lower than thresholdMcps, measurement is treated as
+// "no target" and distance is reported as 8190 mm (out of range).
+// Like in ST API, check is performed by the library, not by the sensor.
+//
+// Note, that threshold is compared with signal rate before crosstalk
+// compensation (CROSSTALK_COMPENSATION_PEAK_RATE_MCPS), which subtract
+// cover glass contribution in the sensor itself. ST recommends threshold
+// equal to 1.5 x crosstalk compensation rate; don't choose threshold
+// so high, that it rejects signal already corrected by compensation.
+func (v *Vl53l0x) SetRangeIgnoreThreshold(enabled bool, thresholdMcps float32) error {
+	if thresholdMcps < 0 || thresholdMcps > 511.99 {
+		return errors.New("out of MCPS range")
+	}
+	v.rangeIgnoreEnabled = enabled
+	v.rangeIgnoreThresholdMcps = thresholdMcps
+	return nil
+}
+
+// GetRangeIgnoreThreshold returns range ignore threshold check
+// settings specified by SetRangeIgnoreThreshold.
+func (v *Vl53l0x) GetRangeIgnoreThreshold() (enabled bool, thresholdMcps float32) {
+	return v.rangeIgnoreEnabled, v.rangeIgnoreThresholdMcps
+}
+
 // TCC: Target CentreCheck
 // MSRC: Minimum Signal Rate Check
 // DSS: Dynamic Spad Selection
@@ -777,7 +1191,7 @@ type SequenceStepTimeouts struct {
 // Based on VL53L0X_GetSequenceStepEnables().
 func (v *Vl53l0x) getSequenceStepEnables(i2c *i2c.I2C) (*SequenceStepEnables, error) {
 
-	lg.Debug("Start getting sequence step enables")
+	v.debug("Start getting sequence step enables")
 
 	sequenceConfig, err := v.readRegU8(i2c, SYSTEM_SEQUENCE_CONFIG)
 	if err != nil {
@@ -794,6 +1208,32 @@ func (v *Vl53l0x) getSequenceStepEnables(i2c *i2c.I2C) (*SequenceStepEnables, er
 	return se, nil
 }
 
+// GetSequenceConfig read SYSTEM_SEQUENCE_CONFIG register, which enables
+// ranging sequence steps: TCC (bit 4), MSRC (bit 2), DSS (bit 3),
+// pre-range (bit 6) and final range (bit 7).
+func (v *Vl53l0x) GetSequenceConfig(i2c *i2c.I2C) (byte, error) {
+	return v.readRegU8(i2c, SYSTEM_SEQUENCE_CONFIG)
+}
+
+// SetSequenceConfig write SYSTEM_SEQUENCE_CONFIG register (see GetSequenceConfig),
+// then re-apply measurement timing budget, since sequence steps enabled
+// change the way budget is split among steps (final range timeout
+// is recalculated). Based on VL53L0X_SetSequenceStepEnable().
+func (v *Vl53l0x) SetSequenceConfig(i2c *i2c.I2C, config byte) error {
+
+	v.debugf("Set sequence config to 0x%x", config)
+
+	err := v.ensureTimingBudget(i2c)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, config)
+	if err != nil {
+		return err
+	}
+	return v.SetMeasurementTimingBudget(i2c, v.measurementTimingBudgetUsec)
+}
+
 // Decode VCSEL (vertical cavity surface emitting laser) pulse period in PCLKs
 // from register value. Based on VL53L0X_decode_vcsel_period().
 func (v *Vl53l0x) decodeVcselPeriod(value byte) byte {
@@ -835,7 +1275,87 @@ func (v *Vl53l0x) timeoutMicrosecondsToMclks(timeoutPeriodUsec uint32, vcselPeri
 //  final: 8 to 14 (initialized default: 10).
 // Based on VL53L0X_set_vcsel_pulse_period().
 func (v *Vl53l0x) SetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType, periodPclks uint8) error {
-	vcselPeriodReg := v.encodeVcselPeriod(periodPclks)
+	// "Apply specific settings for the requested clock period"
+	settings, err := v.planVcselPeriodSettings(tpe, periodPclks)
+	if err != nil {
+		return err
+	}
+
+	err = v.ensureTimingBudget(i2c)
+	if err != nil {
+		return err
+	}
+	err = v.applyVcselPeriodSettings(i2c, tpe, periodPclks, settings)
+	if err != nil {
+		return err
+	}
+
+	// "Finally, the timing budget must be re-applied"
+
+	err = v.SetMeasurementTimingBudget(i2c, v.measurementTimingBudgetUsec)
+	if err != nil {
+		return err
+	}
+
+	// "Perform the phase calibration. This is needed after changing on vcsel period."
+	return v.performPhaseCalibration(i2c)
+}
+
+// SetVcselPulsePeriods set the VCSEL pulse periods for both pre-range and final range
+// (see SetVcselPulsePeriod for valid values). Both periods are validated before
+// any change is made. Timing budget is re-applied, and phase calibration is
+// performed only once, so it's faster than two SetVcselPulsePeriod calls.
+func (v *Vl53l0x) SetVcselPulsePeriods(i2c *i2c.I2C, prePclks, finalPclks uint8) error {
+	preSettings, err := v.planVcselPeriodSettings(VcselPeriodPreRange, prePclks)
+	if err != nil {
+		return err
+	}
+	finalSettings, err := v.planVcselPeriodSettings(VcselPeriodFinalRange, finalPclks)
+	if err != nil {
+		return err
+	}
+
+	err = v.ensureTimingBudget(i2c)
+	if err != nil {
+		return err
+	}
+	err = v.applyVcselPeriodSettings(i2c, VcselPeriodPreRange, prePclks, preSettings)
+	if err != nil {
+		return err
+	}
+	err = v.applyVcselPeriodSettings(i2c, VcselPeriodFinalRange, finalPclks, finalSettings)
+	if err != nil {
+		return err
+	}
+
+	// "Finally, the timing budget must be re-applied"
+
+	err = v.SetMeasurementTimingBudget(i2c, v.measurementTimingBudgetUsec)
+	if err != nil {
+		return err
+	}
+
+	return v.performPhaseCalibration(i2c)
+}
+
+// Make sure, that measurement timing budget stored for reuse is known,
+// since it should be re-applied after VCSEL pulse period change.
+// If budget was never set (neither by Init, nor by SetMeasurementTimingBudget),
+// take actual one from the sensor, before period is changed.
+func (v *Vl53l0x) ensureTimingBudget(i2c *i2c.I2C) error {
+	if v.measurementTimingBudgetUsec != 0 {
+		return nil
+	}
+	v.debug("Timing budget is unknown, read it from the sensor")
+	_, err := v.getMeasurementTimingBudget(i2c)
+	return err
+}
+
+// Write register values specific for the requested VCSEL pulse period,
+// and rewrite corresponding sequence step timeouts to keep them
+// the same in microseconds. Based on VL53L0X_set_vcsel_pulse_period().
+func (v *Vl53l0x) applyVcselPeriodSettings(i2c *i2c.I2C, tpe VcselPeriodType, periodPclks uint8,
+	settings []RegBytePair) error {
 
 	enables, err := v.getSequenceStepEnables(i2c)
 	if err != nil {
@@ -846,7 +1366,11 @@ func (v *Vl53l0x) SetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType, periodP
 		return err
 	}
 
-	// "Apply specific settings for the requested clock period"
+	err = v.writeRegValues(i2c, settings...)
+	if err != nil {
+		return err
+	}
+
 	// "Re-calculate and apply timeouts, in macro periods"
 
 	// "When the VCSEL period for the pre or final range is changed,
@@ -859,43 +1383,6 @@ func (v *Vl53l0x) SetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType, periodP
 	// dependant on the pre-range vcsel period."
 
 	if tpe == VcselPeriodPreRange {
-		// "Set phase check limits"
-		switch periodPclks {
-		case 12:
-			err := v.writeRegU8(i2c, PRE_RANGE_CONFIG_VALID_PHASE_HIGH, 0x18)
-			if err != nil {
-				return err
-			}
-		case 14:
-			err := v.writeRegU8(i2c, PRE_RANGE_CONFIG_VALID_PHASE_HIGH, 0x30)
-			if err != nil {
-				return err
-			}
-		case 16:
-			err := v.writeRegU8(i2c, PRE_RANGE_CONFIG_VALID_PHASE_HIGH, 0x40)
-			if err != nil {
-				return err
-			}
-		case 18:
-			err := v.writeRegU8(i2c, PRE_RANGE_CONFIG_VALID_PHASE_HIGH, 0x50)
-			if err != nil {
-				return err
-			}
-		default:
-			// invalid period
-			return errors.New("invalid period")
-		}
-		err = v.writeRegU8(i2c, PRE_RANGE_CONFIG_VALID_PHASE_LOW, 0x08)
-		if err != nil {
-			return err
-		}
-
-		// apply new VCSEL period
-		err = v.writeRegU8(i2c, PRE_RANGE_CONFIG_VCSEL_PERIOD, vcselPeriodReg)
-		if err != nil {
-			return err
-		}
-
 		// update timeouts
 
 		// set_sequence_step_timeout() begin
@@ -929,71 +1416,7 @@ func (v *Vl53l0x) SetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType, periodP
 		}
 
 		// set_sequence_step_timeout() end
-	} else if tpe == VcselPeriodFinalRange {
-		switch periodPclks {
-		case 8:
-			err := v.writeRegValues(i2c, []RegBytePair{
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_HIGH, Value: 0x10},
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_LOW, Value: 0x08},
-				{Reg: GLOBAL_CONFIG_VCSEL_WIDTH, Value: 0x02},
-				{Reg: ALGO_PHASECAL_CONFIG_TIMEOUT, Value: 0x0C},
-				{Reg: 0xFF, Value: 0x01},
-				{Reg: ALGO_PHASECAL_LIM, Value: 0x30},
-				{Reg: 0xFF, Value: 0x00},
-			}...)
-			if err != nil {
-				return err
-			}
-		case 10:
-			err := v.writeRegValues(i2c, []RegBytePair{
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_HIGH, Value: 0x28},
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_LOW, Value: 0x08},
-				{Reg: GLOBAL_CONFIG_VCSEL_WIDTH, Value: 0x03},
-				{Reg: ALGO_PHASECAL_CONFIG_TIMEOUT, Value: 0x09},
-				{Reg: 0xFF, Value: 0x01},
-				{Reg: ALGO_PHASECAL_LIM, Value: 0x20},
-				{Reg: 0xFF, Value: 0x00},
-			}...)
-			if err != nil {
-				return err
-			}
-		case 12:
-			err := v.writeRegValues(i2c, []RegBytePair{
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_HIGH, Value: 0x38},
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_LOW, Value: 0x08},
-				{Reg: GLOBAL_CONFIG_VCSEL_WIDTH, Value: 0x03},
-				{Reg: ALGO_PHASECAL_CONFIG_TIMEOUT, Value: 0x08},
-				{Reg: 0xFF, Value: 0x01},
-				{Reg: ALGO_PHASECAL_LIM, Value: 0x20},
-				{Reg: 0xFF, Value: 0x00},
-			}...)
-			if err != nil {
-				return err
-			}
-		case 14:
-			err := v.writeRegValues(i2c, []RegBytePair{
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_HIGH, Value: 0x48},
-				{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_LOW, Value: 0x08},
-				{Reg: GLOBAL_CONFIG_VCSEL_WIDTH, Value: 0x03},
-				{Reg: ALGO_PHASECAL_CONFIG_TIMEOUT, Value: 0x07},
-				{Reg: 0xFF, Value: 0x01},
-				{Reg: ALGO_PHASECAL_LIM, Value: 0x20},
-				{Reg: 0xFF, Value: 0x00},
-			}...)
-			if err != nil {
-				return err
-			}
-		default:
-			// invalid period
-			return errors.New("invalid period")
-		}
-
-		// apply new VCSEL period
-		err = v.writeRegU8(i2c, FINAL_RANGE_CONFIG_VCSEL_PERIOD, vcselPeriodReg)
-		if err != nil {
-			return err
-		}
-
+	} else {
 		// update timeouts
 
 		// set_sequence_step_timeout() begin
@@ -1018,200 +1441,1385 @@ func (v *Vl53l0x) SetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType, periodP
 		}
 
 		// set_sequence_step_timeout end
-	} else {
+	}
+
+	return nil
+}
+
+// Perform the phase calibration.
+// Based on VL53L0X_perform_phase_calibration().
+func (v *Vl53l0x) performPhaseCalibration(i2c *i2c.I2C) error {
+	sequenceConfig, err := v.readRegU8(i2c, SYSTEM_SEQUENCE_CONFIG)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, 0x02)
+	if err != nil {
+		return err
+	}
+	err = v.performSingleRefCalibration(i2c, 0x0)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, sequenceConfig)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Return register values specific for the requested VCSEL pulse period,
+// including period register itself. Based on VL53L0X_set_vcsel_pulse_period().
+func (v *Vl53l0x) planVcselPeriodSettings(tpe VcselPeriodType, periodPclks uint8) ([]RegBytePair, error) {
+	vcselPeriodReg := v.encodeVcselPeriod(periodPclks)
+
+	switch tpe {
+	case VcselPeriodPreRange:
+		// "Set phase check limits"
+		var phaseHigh byte
+		switch periodPclks {
+		case 12:
+			phaseHigh = 0x18
+		case 14:
+			phaseHigh = 0x30
+		case 16:
+			phaseHigh = 0x40
+		case 18:
+			phaseHigh = 0x50
+		default:
+			// invalid period
+			return nil, errors.New("invalid period")
+		}
+		return []RegBytePair{
+			{Reg: PRE_RANGE_CONFIG_VALID_PHASE_HIGH, Value: phaseHigh},
+			{Reg: PRE_RANGE_CONFIG_VALID_PHASE_LOW, Value: 0x08},
+			// apply new VCSEL period
+			{Reg: PRE_RANGE_CONFIG_VCSEL_PERIOD, Value: vcselPeriodReg},
+		}, nil
+	case VcselPeriodFinalRange:
+		var phaseHigh, vcselWidth, phasecalTimeout, phasecalLim byte
+		switch periodPclks {
+		case 8:
+			phaseHigh, vcselWidth, phasecalTimeout, phasecalLim = 0x10, 0x02, 0x0C, 0x30
+		case 10:
+			phaseHigh, vcselWidth, phasecalTimeout, phasecalLim = 0x28, 0x03, 0x09, 0x20
+		case 12:
+			phaseHigh, vcselWidth, phasecalTimeout, phasecalLim = 0x38, 0x03, 0x08, 0x20
+		case 14:
+			phaseHigh, vcselWidth, phasecalTimeout, phasecalLim = 0x48, 0x03, 0x07, 0x20
+		default:
+			// invalid period
+			return nil, errors.New("invalid period")
+		}
+		return []RegBytePair{
+			{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_HIGH, Value: phaseHigh},
+			{Reg: FINAL_RANGE_CONFIG_VALID_PHASE_LOW, Value: 0x08},
+			{Reg: GLOBAL_CONFIG_VCSEL_WIDTH, Value: vcselWidth},
+			{Reg: ALGO_PHASECAL_CONFIG_TIMEOUT, Value: phasecalTimeout},
+			{Reg: 0xFF, Value: 0x01},
+			{Reg: ALGO_PHASECAL_LIM, Value: phasecalLim},
+			{Reg: 0xFF, Value: 0x00},
+			// apply new VCSEL period
+			{Reg: FINAL_RANGE_CONFIG_VCSEL_PERIOD, Value: vcselPeriodReg},
+		}, nil
+	default:
 		// invalid type
-		return errors.New("invalid type")
+		return nil, errors.New("invalid type")
+	}
+}
+
+// SetVcselPulsePeriodChecked set the VCSEL pulse period the same way
+// as SetVcselPulsePeriod does, then read period back to verify
+// that write took effect.
+func (v *Vl53l0x) SetVcselPulsePeriodChecked(i2c *i2c.I2C, tpe VcselPeriodType, periodPclks uint8) error {
+	err := v.SetVcselPulsePeriod(i2c, tpe, periodPclks)
+	if err != nil {
+		return err
+	}
+	period, err := v.getVcselPulsePeriod(i2c, tpe)
+	if err != nil {
+		return err
+	}
+	if period != periodPclks {
+		return errors.New(spew.Sprintf("VCSEL pulse period verification failed: "+
+			"requested %d PCLKs, read back %d PCLKs", periodPclks, period))
+	}
+	return nil
+}
+
+// CanSetVcselPulsePeriod check, without writing anything to the sensor,
+// that VCSEL pulse period could be set by SetVcselPulsePeriod: period is valid
+// for the type, and sequence step timeouts, recalculated for the new period,
+// still fit current measurement timing budget. If not, returns false
+// and the reason.
+func (v *Vl53l0x) CanSetVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType,
+	periodPclks uint8) (bool, string, error) {
+
+	_, err := v.planVcselPeriodSettings(tpe, periodPclks)
+	if err != nil {
+		return false, spew.Sprintf("%d PCLKs is invalid VCSEL pulse period", periodPclks), nil
+	}
+	budgetUsec := v.measurementTimingBudgetUsec
+	if budgetUsec == 0 {
+		budgetUsec, err = v.QueryTimingBudget(i2c)
+		if err != nil {
+			return false, "", err
+		}
+	}
+	enables, err := v.getSequenceStepEnables(i2c)
+	if err != nil {
+		return false, "", err
+	}
+	timeouts, err := v.getSequenceStepTimeouts(i2c, *enables)
+	if err != nil {
+		return false, "", err
+	}
+
+	// timeouts are kept in microseconds, but rounded to macro periods
+	// of new VCSEL pulse period (see applyVcselPeriodSettings)
+	if tpe == VcselPeriodPreRange {
+		preRangeMclks := v.timeoutMicrosecondsToMclks(timeouts.PreRangeUsec, uint16(periodPclks))
+		if preRangeMclks > math.MaxUint16 {
+			return false, "pre-range timeout doesn't fit register", nil
+		}
+		timeouts.PreRangeVcselPeriodPclks = uint16(periodPclks)
+		timeouts.PreRangeMclks = uint16(preRangeMclks)
+		timeouts.PreRangeUsec = v.timeoutMclksToMicroseconds(timeouts.PreRangeMclks,
+			timeouts.PreRangeVcselPeriodPclks)
+		msrcMclks := v.timeoutMicrosecondsToMclks(timeouts.MsrcDssTccUsec, uint16(periodPclks))
+		if msrcMclks > 256 {
+			msrcMclks = 256
+		}
+		timeouts.MsrcDssTccUsec = v.timeoutMclksToMicroseconds(uint16(msrcMclks),
+			timeouts.PreRangeVcselPeriodPclks)
+	} else {
+		timeouts.FinalRangeVcselPeriodPclks = uint16(periodPclks)
+	}
+
+	usedBudgetUsec := v.usedBudget(*enables, *timeouts)
+	if usedBudgetUsec > budgetUsec {
+		return false, spew.Sprintf("sequence steps take %d us, which exceeds timing budget %d us",
+			usedBudgetUsec, budgetUsec), nil
+	}
+	if enables.FinalRange {
+		finalRangeMclks := v.timeoutMicrosecondsToMclks(budgetUsec-usedBudgetUsec,
+			timeouts.FinalRangeVcselPeriodPclks)
+		if enables.PreRange {
+			finalRangeMclks += uint32(timeouts.PreRangeMclks)
+		}
+		if finalRangeMclks > math.MaxUint16 {
+			return false, "final range timeout doesn't fit register", nil
+		}
+	}
+	return true, "", nil
+}
+
+// Get the VCSEL pulse period in PCLKs for the given period type.
+// Based on VL53L0X_get_vcsel_pulse_period().
+func (v *Vl53l0x) getVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType) (byte, error) {
+
+	v.debug("Start getting VCSEL pulse period")
+
+	switch tpe {
+	case VcselPeriodPreRange:
+		u8, err := v.readRegU8(i2c, PRE_RANGE_CONFIG_VCSEL_PERIOD)
+		if err != nil {
+			return 0, err
+		}
+		return v.decodeVcselPeriod(u8), nil
+	case VcselPeriodFinalRange:
+		u8, err := v.readRegU8(i2c, FINAL_RANGE_CONFIG_VCSEL_PERIOD)
+		if err != nil {
+			return 0, err
+		}
+		return v.decodeVcselPeriod(u8), nil
+	default:
+		return 0, errors.New("invalid VCSEL period type specified")
+	}
+}
+
+// StartContinuous start continuous ranging measurements. If period_ms (optional) is 0 or not
+// given, continuous back-to-back mode is used (the sensor takes measurements as
+// often as possible); otherwise, continuous timed mode is used, with the given
+// inter-measurement period in milliseconds determining how often the sensor
+// takes a measurement. Based on VL53L0X_StartMeasurement().
+// Note, that the sensor can't take measurements faster than measurement
+// timing budget allows, so period shorter than timing budget results
+// in back-to-back measurements with timing budget rate: warning is logged
+// then, or error is returned (see EnforceMinimumPeriod).
+func (v *Vl53l0x) StartContinuous(i2c *i2c.I2C, periodMs uint32) error {
+
+	v.debug("Start continuous")
+
+	if periodMs != 0 {
+		err := v.checkInterMeasurementPeriod(i2c, periodMs)
+		if err != nil {
+			return err
+		}
+	}
+	err := v.writeStopVariable(i2c)
+	if err != nil {
+		return err
+	}
+	if periodMs != 0 {
+		// continuous timed mode
+
+		// VL53L0X_SetInterMeasurementPeriodMilliSeconds() begin
+
+		oscCalibrateVal, err := v.GetOscCalibrateVal(i2c)
+		if err != nil {
+			return err
+		}
+
+		periodTicks, err := interMeasurementPeriodTicks(periodMs, oscCalibrateVal)
+		if err != nil {
+			return err
+		}
+
+		// register is written MSB first, same as
+		// VL53L0X_WrDWord() does in ST API
+		err = v.writeRegU32(i2c, SYSTEM_INTERMEASUREMENT_PERIOD, periodTicks)
+		if err != nil {
+			return err
+		}
+
+		// VL53L0X_SetInterMeasurementPeriodMilliSeconds() end
+
+		err = v.writeRegU8(i2c, SYSRANGE_START, 0x04) // VL53L0X_REG_SYSRANGE_MODE_TIMED
+		if err != nil {
+			return err
+		}
+	} else {
+		// continuous back-to-back mode
+		err = v.writeRegU8(i2c, SYSRANGE_START, 0x02) // VL53L0X_REG_SYSRANGE_MODE_BACKTOBACK
+		if err != nil {
+			return err
+		}
+	}
+	v.continuous = true
+	v.continuousPeriodMs = periodMs
+	// pending interrupt (if any) belongs to previous measurements
+	v.resultArmed = false
+	return nil
+}
+
+// EnforceMinimumPeriod set whether StartContinuous rejects inter-measurement
+// period shorter than measurement timing budget (which includes sequence
+// overheads), instead of logging warning. Disabled by default.
+func (v *Vl53l0x) EnforceMinimumPeriod(enforce bool) {
+	v.enforceMinimumPeriod = enforce
+}
+
+// Check that inter-measurement period isn't shorter
+// than measurement takes, according to timing budget.
+func (v *Vl53l0x) checkInterMeasurementPeriod(i2c *i2c.I2C, periodMs uint32) error {
+	err := v.ensureTimingBudget(i2c)
+	if err != nil {
+		return err
+	}
+	if uint64(periodMs)*1000 >= uint64(v.measurementTimingBudgetUsec) {
+		return nil
+	}
+	if v.enforceMinimumPeriod {
+		return errors.New(spew.Sprintf("inter-measurement period %d ms is shorter "+
+			"than timing budget %d us", periodMs, v.measurementTimingBudgetUsec))
+	}
+	v.warningf("Inter-measurement period %d ms is shorter than timing budget %d us, "+
+		"so measurements will be taken back-to-back", periodMs, v.measurementTimingBudgetUsec)
+	return nil
+}
+
+// GetOscCalibrateVal returns oscillator calibration value, used by StartContinuous
+// to scale inter-measurement period in timed mode. Zero value means module
+// isn't calibrated, so period is applied unscaled and timed mode cadence
+// would be wrong.
+func (v *Vl53l0x) GetOscCalibrateVal(i2c *i2c.I2C) (uint16, error) {
+	return v.readRegU16(i2c, OSC_CALIBRATE_VAL)
+}
+
+// Convert inter-measurement period in milliseconds to SYSTEM_INTERMEASUREMENT_PERIOD
+// register value. Period is in oscillator ticks, so milliseconds are scaled
+// by calibrated number of ticks per millisecond (unless it's zero).
+func interMeasurementPeriodTicks(periodMs uint32, oscCalibrateVal uint16) (uint32, error) {
+	if oscCalibrateVal == 0 {
+		return periodMs, nil
+	}
+	if periodMs > math.MaxUint32/uint32(oscCalibrateVal) {
+		return 0, errors.New(spew.Sprintf("inter-measurement period %d ms is too long", periodMs))
+	}
+	return periodMs * uint32(oscCalibrateVal), nil
+}
+
+// Write stop variable read by Init to internal register,
+// which is required before measurement start.
+func (v *Vl53l0x) writeStopVariable(i2c *i2c.I2C) error {
+	err := v.withPowerForce(i2c, func() error {
+		return v.withPage(i2c, 0x01, func() error {
+			return v.writeRegU8(i2c, 0x91, v.stopVariable)
+		})
+	})
+	v.stopVariableWritten = err == nil
+	return err
+}
+
+// StartContinuousAndWaitReady start continuous ranging measurements the same way
+// as StartContinuous does, then confirm that the first measurement result becomes
+// available within timeout. Otherwise continuous mode is stopped and error returned,
+// since it's likely that sensor didn't actually start measuring.
+// First measurement result is kept unread, so it can be taken
+// with ReadRangeContinuousMillimeters.
+func (v *Vl53l0x) StartContinuousAndWaitReady(i2c *i2c.I2C, periodMs uint32,
+	timeout time.Duration) error {
+
+	err := v.StartContinuous(i2c, periodMs)
+	if err != nil {
+		return err
+	}
+
+	v.debug("Wait for first continuous measurement ready")
+
+	st := v.startTimeout()
+	for {
+		u8, err := v.readRegU8(i2c, RESULT_INTERRUPT_STATUS)
+		if err != nil {
+			return err
+		}
+		if v.isDataReady(u8) {
+			break
+		}
+		if v.checkTimeoutExpiredAfter(st, timeout) {
+			v.metrics.addTimeout()
+			v.errorf("No continuous measurement ready within %v", timeout)
+			err = v.StopContinuous(i2c)
+			if err != nil {
+				return err
+			}
+			return errors.New(spew.Sprintf("continuous mode didn't start: "+
+				"no measurement ready within %v", timeout))
+		}
+	}
+	return nil
+}
+
+// StopContinuous stop continuous measurements.
+// Does nothing, if continuous mode isn't active, so it's safe
+// to call in cleanup code regardless of current mode.
+// Based on VL53L0X_StopMeasurement().
+func (v *Vl53l0x) StopContinuous(i2c *i2c.I2C) error {
+	if !v.continuous {
+		v.debug("Continuous mode isn't active, nothing to stop")
+		return nil
+	}
+
+	v.debug("Stop continuous")
+
+	err := v.writeRegU8(i2c, SYSRANGE_START, 0x01) // VL53L0X_REG_SYSRANGE_MODE_SINGLESHOT
+	if err != nil {
+		return err
+	}
+	v.stopVariableWritten = false
+	err = v.withPage(i2c, 0x01, func() error {
+		return v.writeRegU8(i2c, 0x91, 0x00)
+	})
+	if err != nil {
+		return err
+	}
+	v.continuous = false
+	return nil
+}
+
+// SwitchToSingleShot stop continuous mode, if active, and wait until
+// sensor completes measurement in progress, so it's ready for single-shot
+// measurements. Pending interrupt is cleared.
+func (v *Vl53l0x) SwitchToSingleShot(i2c *i2c.I2C) error {
+
+	v.debug("Switch to single-shot mode")
+
+	return v.stopToIdle(i2c)
+}
+
+// SwitchToContinuous stop continuous mode, if active, wait until sensor
+// completes measurement in progress, then start continuous mode with
+// periodMs (see StartContinuous). Allows to change period of active
+// continuous mode as well.
+func (v *Vl53l0x) SwitchToContinuous(i2c *i2c.I2C, periodMs uint32) error {
+
+	v.debug("Switch to continuous mode")
+
+	err := v.stopToIdle(i2c)
+	if err != nil {
+		return err
+	}
+	return v.StartContinuous(i2c, periodMs)
+}
+
+// LaserOff ensure that VCSEL doesn't emit: stop continuous mode (if active),
+// wait until measurement in progress (if any) completes, clear pending
+// interrupt and put sensor to software standby. Sensor keeps its settings,
+// and leaves standby on next measurement start.
+// Based on VL53L0X_StopMeasurement() and VL53L0X_SetPowerMode().
+func (v *Vl53l0x) LaserOff(i2c *i2c.I2C) error {
+
+	v.debug("Laser off")
+
+	continuous := v.continuous
+	err := v.stopToIdle(i2c)
+	if err != nil {
+		return err
+	}
+	if !continuous {
+		// single-shot measurement might be in progress
+		err = v.waitStopCompleted(i2c)
+		if err != nil {
+			return err
+		}
+	}
+	// VL53L0X_POWERMODE_STANDBY_LEVEL1
+	return v.writeRegU8(i2c, POWER_MANAGEMENT_GO1_POWER_FORCE, 0x00)
+}
+
+// ErrClosed is returned by methods communicating with the sensor,
+// once Close is called, until sensor is initialized again.
+var ErrClosed = errors.New("sensor is closed")
+
+// Close turn off laser (see LaserOff) and mark sensor as closed,
+// so any subsequent communication with the sensor fails with ErrClosed,
+// rather than with low-level errors of closed connection. Connection
+// itself is left open, since it's owned by caller. Sensor is marked
+// as closed even if laser turn off fails. Repeated calls do nothing.
+//
+// Close might be called from another goroutine, while measurement is
+// in progress: closed state is tracked atomically, so measurement fails
+// with ErrClosed on next register access once Close completes (or with
+// timeout, if laser is turned off under it). Closed sensor could be used
+// again only after Init (or InitWithOptions), which clears closed state.
+func (v *Vl53l0x) Close(i2c *i2c.I2C) error {
+	if v.isClosed() {
+		return nil
+	}
+
+	v.debug("Close")
+
+	err := v.LaserOff(i2c)
+	v.setClosed(true)
+	return err
+}
+
+// Check that Close was called.
+func (v *Vl53l0x) isClosed() bool {
+	return atomic.LoadUint32(&v.closed) != 0
+}
+
+// Mark sensor as closed, or opened again.
+func (v *Vl53l0x) setClosed(closed bool) {
+	var u32 uint32
+	if closed {
+		u32 = 1
+	}
+	atomic.StoreUint32(&v.closed, u32)
+}
+
+// SetXShut set function driving sensor XSHUT pin: low level (false) keeps
+// sensor in hardware standby, high level (true) let it boot. Required
+// by ReadRangeSingleLowPower. Nil value removes it.
+func (v *Vl53l0x) SetXShut(setXShut func(high bool) error) {
+	v.setXShut = setXShut
+}
+
+// ReadRangeSingleLowPower performs a single-shot range measurement
+// for infrequent sampling, keeping sensor in hardware standby (XSHUT pin low,
+// see SetXShut) between measurements, where it draws the lowest current.
+// Sensor loses all settings in hardware standby, so they're read from
+// the sensor before it's put to standby first time: timing budget, VCSEL
+// pulse periods, sequence steps, limit check enables, signal rate limit,
+// offset and crosstalk calibration. On each subsequent call sensor is
+// released from standby, initialized with options used by Init last time,
+// and settings are restored. Settings kept by the library (like sigma limit,
+// range ignore threshold or linearity corrective gain) aren't affected.
+// Measurement is taken, then sensor is put back to hardware standby,
+// even if measurement failed. Since sensor
+// address is reset to DefaultAddress in hardware standby, i2c should be
+// connected to DefaultAddress. Sensor doesn't respond between calls.
+// Wake up and initialization take tens of milliseconds, so use it
+// for sampling once per seconds or rarer.
+func (v *Vl53l0x) ReadRangeSingleLowPower(i2c *i2c.I2C) (rng uint16, err error) {
+
+	v.debug("Read range single low power")
+
+	if v.setXShut == nil {
+		return 0, errors.New("XSHUT pin control isn't set, see SetXShut")
+	}
+	if addr := i2c.GetAddr(); addr != DefaultAddress {
+		return 0, errors.New(spew.Sprintf("sensor address 0x%x is lost in hardware standby, "+
+			"use default address 0x%x", addr, DefaultAddress))
+	}
+
+	if v.hardwareStandby {
+		err = v.wakeUpFromHardwareStandby(i2c)
+	} else {
+		if v.continuous {
+			err = v.stopToIdle(i2c)
+			if err != nil {
+				return 0, err
+			}
+		}
+		// sensor isn't put to standby, if settings can't be restored later
+		v.standbySettings, err = v.captureStandbySettings(i2c)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if err == nil {
+		rng, err = v.ReadRangeSingleMillimeters(i2c)
+	}
+
+	v.debug("Put sensor to hardware standby")
+
+	err2 := v.setXShut(false)
+	if err2 != nil {
+		if err == nil {
+			err = err2
+		}
+		return 0, err
+	}
+	v.hardwareStandby = true
+	// hardware standby drops all sensor settings
+	v.forgetState()
+	if err != nil {
+		return 0, err
+	}
+	return rng, nil
+}
+
+// Sensor settings, which are lost in hardware standby.
+type standbySettings struct {
+	budgetUsec      uint32
+	prePclks        uint8
+	finalPclks      uint8
+	sequenceConfig  byte
+	msrcControl     byte
+	signalRateLimit uint16
+	offset          uint16
+	crosstalk       uint16
+}
+
+// Read sensor settings, which are lost in hardware standby.
+func (v *Vl53l0x) captureStandbySettings(i2c *i2c.I2C) (*standbySettings, error) {
+	err := v.ensureTimingBudget(i2c)
+	if err != nil {
+		return nil, err
+	}
+	settings := &standbySettings{budgetUsec: v.measurementTimingBudgetUsec}
+	settings.prePclks, err = v.getVcselPulsePeriod(i2c, VcselPeriodPreRange)
+	if err != nil {
+		return nil, err
+	}
+	settings.finalPclks, err = v.getVcselPulsePeriod(i2c, VcselPeriodFinalRange)
+	if err != nil {
+		return nil, err
+	}
+	settings.sequenceConfig, err = v.GetSequenceConfig(i2c)
+	if err != nil {
+		return nil, err
+	}
+	settings.msrcControl, err = v.readRegU8(i2c, MSRC_CONFIG_CONTROL)
+	if err != nil {
+		return nil, err
+	}
+	settings.signalRateLimit, err = v.readRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT)
+	if err != nil {
+		return nil, err
+	}
+	settings.offset, err = v.readRegU16(i2c, ALGO_PART_TO_PART_RANGE_OFFSET_MM)
+	if err != nil {
+		return nil, err
+	}
+	settings.crosstalk, err = v.readRegU16(i2c, CROSSTALK_COMPENSATION_PEAK_RATE_MCPS)
+	if err != nil {
+		return nil, err
+	}
+	v.debugf("Settings to restore after hardware standby = %#v", settings)
+	return settings, nil
+}
+
+// Release sensor from hardware standby, wait for boot, then initialize it
+// and restore settings read before standby (see captureStandbySettings).
+func (v *Vl53l0x) wakeUpFromHardwareStandby(i2c *i2c.I2C) error {
+
+	v.debug("Wake up sensor from hardware standby")
+
+	settings := v.standbySettings
+	if settings == nil {
+		return errors.New("sensor settings to restore after hardware standby are unknown")
+	}
+	err := v.setXShut(true)
+	if err != nil {
+		return err
+	}
+	err = v.WaitForBoot(i2c, bootTimeout)
+	if err != nil {
+		return err
+	}
+	err = v.InitWithOptions(i2c, v.initOptions)
+	if err != nil {
+		return err
+	}
+	v.hardwareStandby = false
+
+	err = v.SetSequenceConfig(i2c, settings.sequenceConfig)
+	if err != nil {
+		return err
+	}
+	err = v.SetVcselPulsePeriods(i2c, settings.prePclks, settings.finalPclks)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU8(i2c, MSRC_CONFIG_CONTROL, settings.msrcControl)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU16(i2c, FINAL_RANGE_CONFIG_MIN_COUNT_RATE_RTN_LIMIT, settings.signalRateLimit)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU16(i2c, ALGO_PART_TO_PART_RANGE_OFFSET_MM, settings.offset)
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU16(i2c, CROSSTALK_COMPENSATION_PEAK_RATE_MCPS, settings.crosstalk)
+	if err != nil {
+		return err
+	}
+	// VCSEL pulse periods and sequence steps affect timing budget
+	return v.SetMeasurementTimingBudget(i2c, settings.budgetUsec)
+}
+
+// Stop continuous mode, if active, wait for sensor idle state
+// and clear pending interrupt.
+func (v *Vl53l0x) stopToIdle(i2c *i2c.I2C) error {
+	if v.continuous {
+		err := v.StopContinuous(i2c)
+		if err != nil {
+			return err
+		}
+		err = v.waitStopCompleted(i2c)
+		if err != nil {
+			return err
+		}
+	}
+	return v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+}
+
+// Wait until sensor completes measurement in progress after stop.
+// Based on VL53L0X_GetStopCompletedStatus().
+func (v *Vl53l0x) waitStopCompleted(i2c *i2c.I2C) error {
+	return v.withPage(i2c, 0x01, func() error {
+		return v.waitUntilOrTimeout(i2c, 0x04,
+			func(checkReg byte, err error) (bool, error) {
+				return checkReg == 0, err
+			})
+	})
+}
+
+// ClearPendingInterrupt clear interrupt possibly left pending by previous
+// session, and confirm that interrupt status is cleared, so the next
+// measurement result is never stale. Intended to be called after Init,
+// while continuous mode is stopped.
+func (v *Vl53l0x) ClearPendingInterrupt(i2c *i2c.I2C) error {
+
+	v.debug("Clear pending interrupt")
+
+	err := v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+	if err != nil {
+		return err
+	}
+	err = v.waitUntilOrTimeout(i2c, RESULT_INTERRUPT_STATUS,
+		func(checkReg byte, err error) (bool, error) {
+			if err != nil {
+				return false, err
+			}
+			if !v.isDataReady(checkReg) {
+				return true, nil
+			}
+			// repeat clear, till it takes effect
+			return false, v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+		})
+	if err != nil {
+		if errors.Is(err, ErrTimeout) {
+			return fmt.Errorf("interrupt clear timed out: %w", err)
+		}
+		return err
+	}
+	v.resultArmed = true
+	return nil
+}
+
+// Check interrupt status register value for "new sample ready" event
+// (interrupt is configured to this state by Init).
+func (v *Vl53l0x) isDataReady(interruptStatus byte) bool {
+	return interruptStatus&0x07 != 0
+}
+
+// RangeData keeps single measurement result.
+type RangeData struct {
+	// Measured distance in millimeters.
+	RangeMm uint16
+	// Unmodified RESULT_RANGE_STATUS register value (see ReadRawRangeStatus).
+	RangeStatus byte
+	// Estimated maximum detection distance in current setup and ambient
+	// light conditions (not reported by the sensor, but calculated by
+	// the library the same way as by ST API). When distance is reported as
+	// out of range, it tells that there is no target closer than DMax.
+	RangeDMaxMm uint16
+	// Distance likely exceeds unambiguous range and wraps around
+	// to a short one: device reports "phase fail" status.
+	// Such reading shouldn't be trusted.
+	Wrapped bool
+	// Time when measurement result was taken from the sensor.
+	Timestamp time.Time
+	// Sequence number of measurement result, incremented each time
+	// new result is taken from the sensor. Results with the same
+	// sequence number are the same measurement.
+	Sequence uint32
+}
+
+// MaxRangeMm is a distance reported by sensor, when no target detected.
+// Any distance equal or greater than this value means "out of range".
+const MaxRangeMm = 8190
+
+// Distance reported by sensor, when no target detected.
+const outOfRange = MaxRangeMm
+
+// Read measured distance from the sensor.
+func (v *Vl53l0x) readRangeMillimeters(i2c *i2c.I2C) (uint16, error) {
+	data, err := v.readRange(i2c)
+	if err != nil {
+		return 0, err
+	}
+	return data.RangeMm, nil
+}
+
+// Wait for measurement result, then read it.
+func (v *Vl53l0x) readRange(i2c *i2c.I2C) (*RangeData, error) {
+	var buf [resultBlockSize]byte
+	data := &RangeData{}
+	err := v.readRangeInto(i2c, buf[:], data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Wait for measurement result, then read it to out,
+// using buf to read result block.
+func (v *Vl53l0x) readRangeInto(i2c *i2c.I2C, buf []byte, out *RangeData) error {
+
+	err := v.waitMeasurementReady(i2c)
+	if err != nil {
+		return err
+	}
+
+	err = v.readResultInto(i2c, buf, out)
+	if err != nil {
+		return err
+	}
+	err = v.clearResultInterrupt(i2c)
+	if err != nil {
+		return err
+	}
+	v.metrics.addMeasurement(out.RangeMm)
+
+	return nil
+}
+
+// Size of result block from range status up to range value.
+const resultBlockSize = 12
+
+// Read result block from range status up to range value.
+func (v *Vl53l0x) readResultBlock(i2c *i2c.I2C) ([resultBlockSize]byte, error) {
+	var buf [resultBlockSize]byte
+	err := v.readRegBytes(i2c, RESULT_RANGE_STATUS, buf[:])
+	return buf, err
+}
+
+// Read measurement result from result block, once it's ready.
+func (v *Vl53l0x) readResult(i2c *i2c.I2C) (*RangeData, error) {
+	var buf [resultBlockSize]byte
+	data := &RangeData{}
+	err := v.readResultInto(i2c, buf[:], data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Read measurement result from result block to out, once it's ready,
+// using buf to read result block.
+func (v *Vl53l0x) readResultInto(i2c *i2c.I2C, buf []byte, out *RangeData) error {
+	buf = buf[:resultBlockSize]
+	err := v.readRegBytes(i2c, RESULT_RANGE_STATUS, buf)
+	if err != nil {
+		return err
+	}
+	decodeResultBlock(buf, out)
+	out.Timestamp = time.Now()
+	v.measurementSeq++
+	out.Sequence = v.measurementSeq
+	v.lastRangeStatus = out.RangeStatus
+	v.lastRangeIgnoreFail = false
+	v.lastSigmaFail = false
+
+	if v.rangeIgnoreEnabled {
+		// effective SPAD return count in 8.8 fixed point format,
+		// signal rate in Q9.7 fixed point format
+		spadCount := float32(uint16(buf[2])<<8|uint16(buf[3])) / (1 << 8)
+		signalRate := FixedToMCPS(uint16(buf[6])<<8 | uint16(buf[7]))
+		if spadCount == 0 || signalRate/spadCount < v.rangeIgnoreThresholdMcps {
+			v.debugf("Signal rate %v MCPS per SPAD is below range ignore threshold",
+				signalRate/spadCount)
+			out.RangeMm = outOfRange
+			v.lastRangeIgnoreFail = true
+		}
+	}
+
+	sigmaMm, dmaxMm := v.estimateSigma(buf)
+	out.RangeDMaxMm = uint16(dmaxMm)
+	if v.isSigmaFail(sigmaMm) {
+		out.RangeMm = outOfRange
+		v.lastSigmaFail = true
+	}
+
+	return nil
+}
+
+// DistanceFromResultBlock decode measurement result from result block
+// (12 bytes starting from RESULT_RANGE_STATUS register) captured before,
+// without any communication with sensor. Intended for offline analysis
+// of register dumps. Only values reported by the sensor are decoded:
+// checks done by the library (range ignore threshold, sigma limit)
+// aren't applied, and RangeDMaxMm, Timestamp and Sequence are left zero.
+func DistanceFromResultBlock(block []byte) (*RangeData, error) {
+	if len(block) < resultBlockSize {
+		return nil, errors.New(spew.Sprintf("result block is too short: %d bytes, %d required",
+			len(block), resultBlockSize))
+	}
+	data := &RangeData{}
+	decodeResultBlock(block, data)
+	return data, nil
+}
+
+// Decode values reported by the sensor from result block to out.
+func decodeResultBlock(buf []byte, out *RangeData) {
+	*out = RangeData{
+		// assumptions: Linearity Corrective Gain is 1000 (default);
+		// fractional ranging is not enabled
+		RangeMm:     uint16(buf[10])<<8 | uint16(buf[11]),
+		RangeStatus: buf[0],
+	}
+	out.Wrapped = isPhaseFailStatus(out.RangeStatus)
+}
+
+// ReadRangeDataInto performs range measurement like ReadRangeData does,
+// but store result to out, using caller-provided buf (at least 12 bytes long)
+// to read result block, so it could be used in tight loops without extra
+// allocations. When continuous mode is active, it takes next continuous
+// measurement result instead of starting single-shot measurement.
+func (v *Vl53l0x) ReadRangeDataInto(i2c *i2c.I2C, buf []byte, out *RangeData) error {
+	if len(buf) < resultBlockSize {
+		return errors.New(spew.Sprintf("buffer is too short: %d bytes, %d required",
+			len(buf), resultBlockSize))
+	}
+	if !v.continuous {
+		err := v.startSingleRange(i2c)
+		if err != nil {
+			return err
+		}
+	}
+	return v.readRangeInto(i2c, buf, out)
+}
+
+// ReadRangeData performs a single-shot range measurement
+// and returns measurement result with status information.
+func (v *Vl53l0x) ReadRangeData(i2c *i2c.I2C) (*RangeData, error) {
+
+	v.debug("Read range data")
+
+	err := v.startSingleRange(i2c)
+	if err != nil {
+		return nil, err
+	}
+	return v.readRange(i2c)
+}
+
+// DetectTarget performs range measurement and report whether valid target
+// is found within maxMm distance. Use single-shot measurement, unless
+// continuous mode is active. Measurement with any error range status
+// (weak signal, wrap-around, etc) means no target present.
+func (v *Vl53l0x) DetectTarget(i2c *i2c.I2C, maxMm uint16) (present bool,
+	distance uint16, err error) {
+
+	v.debug("Detect target")
+
+	var data *RangeData
+	if v.continuous {
+		data, err = v.readRange(i2c)
+	} else {
+		data, err = v.ReadRangeData(i2c)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	if !v.isRangeValid(data) {
+		return false, data.RangeMm, nil
+	}
+	return data.RangeMm <= maxMm, data.RangeMm, nil
+}
+
+// LastRangeStatus returns unmodified RESULT_RANGE_STATUS register value
+// of last measurement result read by any measurement method (see
+// ReadRawRangeStatus for value meaning), without communication with sensor.
+func (v *Vl53l0x) LastRangeStatus() byte {
+	return v.lastRangeStatus
+}
+
+// ReadRawRangeStatus returns unmodified RESULT_RANGE_STATUS register value,
+// which describe last measurement result. Bits 6..3 keep device range
+// status code (11 means valid range), bit 0 - "new data ready" flag.
+func (v *Vl53l0x) ReadRawRangeStatus(i2c *i2c.I2C) (byte, error) {
+	return v.readRegU8(i2c, RESULT_RANGE_STATUS)
+}
+
+// Extract device range status code from RESULT_RANGE_STATUS register value.
+// Based on VL53L0X_get_pal_range_status().
+func (v *Vl53l0x) decodeDeviceRangeStatus(rangeStatus byte) byte {
+	return (rangeStatus & 0x78) >> 3
+}
+
+// Check that device reports "phase fail" status: phase of return signal
+// is out of valid limits, which happens when distance exceed unambiguous
+// range and reading wraps around to short distance.
+func (v *Vl53l0x) isPhaseFail(rangeStatus byte) bool {
+	return isPhaseFailStatus(rangeStatus)
+}
+
+// Check RESULT_RANGE_STATUS register value for "phase fail" device status.
+func isPhaseFailStatus(rangeStatus byte) bool {
+	status := (rangeStatus & 0x78) >> 3
+	return status == 6 || status == 9
+}
+
+// Check that measurement result is valid: device reports "range valid"
+// status, and distance is within measurable range.
+func (v *Vl53l0x) isRangeValid(data *RangeData) bool {
+	const RangeValid = 11
+	return v.decodeDeviceRangeStatus(data.RangeStatus) == RangeValid &&
+		data.RangeMm < outOfRange
+}
+
+// Check that measurement failed because of weak return signal:
+// either device reports "signal fail" status, or distance
+// is reported as out of range.
+func (v *Vl53l0x) isSignalFail(rng uint16, rangeStatus byte) bool {
+	const SignalFail = 4
+	return v.decodeDeviceRangeStatus(rangeStatus) == SignalFail || rng >= outOfRange
+}
+
+// ReadRangeContinuousMillimeters returns a range reading in millimeters
+// when continuous mode is active (readRangeSingleMillimeters() also calls
+// this function after starting a single-shot range measurement).
+// Each call waits for a new measurement, so the same continuous
+// measurement result is never returned twice (see RangeData.Sequence).
+func (v *Vl53l0x) ReadRangeContinuousMillimeters(i2c *i2c.I2C) (uint16, error) {
+
+	v.debug("Read range continuous")
+
+	return v.readRangeMillimeters(i2c)
+}
+
+// ReadRangeSingleMillimeters performs a single-shot range measurement and returns the reading in
+// millimeters based on VL53L0X_PerformSingleRangingMeasurement().
+// Any pending interrupt is cleared before measurement starts,
+// so returned value is always fresh.
+func (v *Vl53l0x) ReadRangeSingleMillimeters(i2c *i2c.I2C) (uint16, error) {
+
+	v.debug("Read range single")
+
+	err := v.startSingleRange(i2c)
+	if err != nil {
+		return 0, err
+	}
+	return v.readRangeMillimeters(i2c)
+}
+
+// IsRanging read SYSRANGE_START register and returns true,
+// if measurement is in progress (start bit is not cleared yet).
+func (v *Vl53l0x) IsRanging(i2c *i2c.I2C) (bool, error) {
+	u8, err := v.readRegU8(i2c, SYSRANGE_START)
+	if err != nil {
+		return false, err
+	}
+	return u8&0x01 != 0, nil
+}
+
+// SYSRANGE_START register bits, same as VL53L0X_REG_SYSRANGE_MODE_... in ST API.
+const (
+	// Mode bits mask.
+	SysrangeModeMask = 0x0F
+	// Measurement start (write), or measurement in progress (read).
+	SysrangeModeStartStop = 0x01
+	// Single-shot mode (no mode bits set).
+	SysrangeModeSingleShot = 0x00
+	// Continuous back-to-back mode.
+	SysrangeModeBackToBack = 0x02
+	// Continuous timed mode.
+	SysrangeModeTimed = 0x04
+)
+
+// GetSysrangeMode read SYSRANGE_START register and returns its mode bits
+// (see SysrangeMode... constants), which tell in what mode sensor operates:
+// either single-shot, continuous back-to-back or continuous timed. Start bit
+// (SysrangeModeStartStop) is set, while measurement is in progress.
+// Useful after reconnection to running sensor.
+func (v *Vl53l0x) GetSysrangeMode(i2c *i2c.I2C) (byte, error) {
+	u8, err := v.readRegU8(i2c, SYSRANGE_START)
+	if err != nil {
+		return 0, err
+	}
+	return u8 & SysrangeModeMask, nil
+}
+
+// Start single-shot range measurement.
+func (v *Vl53l0x) startSingleRange(i2c *i2c.I2C) error {
+	// clear interrupt possibly left pending by previous measurement
+	// (or previous connection), otherwise stale result might be
+	// taken for the fresh one
+	err := v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+	if err != nil {
+		return err
+	}
+	err = v.writeStopVariable(i2c)
+	if err != nil {
+		return err
+	}
+	return v.triggerSingleRange(i2c)
+}
+
+// ReadRangeSingleFast performs a single-shot range measurement like
+// ReadRangeSingleMillimeters does, but write stop variable preamble
+// (7 register writes) only once, instead of before each measurement,
+// and doesn't clear stale interrupt. Intended for tight single-shot
+// polling loops. Preamble is written again after Reset, Init or StopContinuous.
+func (v *Vl53l0x) ReadRangeSingleFast(i2c *i2c.I2C) (uint16, error) {
+	if !v.stopVariableWritten {
+		err := v.writeStopVariable(i2c)
+		if err != nil {
+			return 0, err
+		}
+	}
+	err := v.triggerSingleRange(i2c)
+	if err != nil {
+		return 0, err
+	}
+	return v.readRangeMillimeters(i2c)
+}
+
+// SetOnMeasurementStart set callback, which is invoked with current time
+// right after single-shot measurement is triggered (SYSRANGE_START register
+// is written) by any single-shot measurement method, like
+// ReadRangeSingleMillimeters. Intended for timing correlation with external
+// events. Callback should return quickly, since measurement is already
+// in progress. Nil value removes callback.
+func (v *Vl53l0x) SetOnMeasurementStart(fn func(time.Time)) {
+	v.onMeasurementStart = fn
+}
+
+// ReadRangeRaw returns range reading in millimeters with minimal register
+// interactions and no logging: takes next continuous measurement result,
+// if continuous mode is active, otherwise performs single-shot measurement,
+// writing stop variable preamble only once (like ReadRangeSingleFast).
+// Library checks (range ignore threshold, sigma limit), measurement start
+// hook and timeout recovery are skipped, and neither range status,
+// nor measurement sequence are tracked. Intended for throughput
+// benchmarks and latency-critical loops.
+func (v *Vl53l0x) ReadRangeRaw(i2c *i2c.I2C) (uint16, error) {
+	st := v.startTimeout()
+	timeout := v.measurementTimeout()
+	if !v.continuous {
+		if !v.stopVariableWritten {
+			err := v.writeStopVariable(i2c)
+			if err != nil {
+				return 0, err
+			}
+		}
+		err := v.writeRegU8(i2c, SYSRANGE_START, 0x01)
+		if err != nil {
+			return 0, err
+		}
+		for {
+			u8, err := v.readRegU8(i2c, SYSRANGE_START)
+			if err != nil {
+				return 0, err
+			} else if u8&0x01 == 0 {
+				break
+			} else if v.checkTimeoutExpiredAfter(st, timeout) {
+				v.metrics.addTimeout()
+				return 0, &timeoutError{reg: SYSRANGE_START, value: u8}
+			}
+		}
+	}
+	for {
+		u8, err := v.readRegU8(i2c, RESULT_INTERRUPT_STATUS)
+		if err != nil {
+			return 0, err
+		} else if v.isDataReady(u8) {
+			break
+		} else if v.checkTimeoutExpiredAfter(st, timeout) {
+			v.metrics.addTimeout()
+			return 0, &timeoutError{reg: RESULT_INTERRUPT_STATUS, value: u8}
+		}
+	}
+	// range value is at the end of result block
+	rng, err := v.readRegU16(i2c, RESULT_RANGE_STATUS+10)
+	if err != nil {
+		return 0, err
+	}
+	err = v.clearResultInterrupt(i2c)
+	if err != nil {
+		return 0, err
 	}
+	return rng, nil
+}
 
-	// "Finally, the timing budget must be re-applied"
-
-	err = v.SetMeasurementTimingBudget(i2c, v.measurementTimingBudgetUsec)
+// Trigger single-shot range measurement, once preamble is written.
+func (v *Vl53l0x) triggerSingleRange(i2c *i2c.I2C) error {
+	err := v.writeSingleRangeStart(i2c)
 	if err != nil {
 		return err
 	}
 
-	// "Perform the phase calibration. This is needed after changing on vcsel period."
-	// VL53L0X_perform_phase_calibration() begin
+	// "Wait until start bit has been cleared"
+	err = v.waitUntilOrTimeout(i2c, SYSRANGE_START,
+		func(checkReg byte, err error) (bool, error) {
+			return checkReg&0x01 == 0, err
+		})
+	return v.recoverIfTimedOut(i2c, err)
+}
 
-	sequenceConfig, err := v.readRegU8(i2c, SYSTEM_SEQUENCE_CONFIG)
+// Write start bit of single-shot range measurement,
+// without waiting for it to be cleared.
+func (v *Vl53l0x) writeSingleRangeStart(i2c *i2c.I2C) error {
+	err := v.writeRegU8(i2c, SYSRANGE_START, 0x01)
 	if err != nil {
 		return err
 	}
-	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, 0x02)
-	if err != nil {
-		return err
+	if v.onMeasurementStart != nil {
+		v.onMeasurementStart(time.Now())
 	}
-	err = v.performSingleRefCalibration(i2c, 0x0)
+	// measurement is started after previous result is taken,
+	// so its result is a new one
+	v.resultArmed = true
+	return nil
+}
+
+// Wait until new measurement result is ready (see isNewResultReady).
+func (v *Vl53l0x) waitMeasurementReady(i2c *i2c.I2C) error {
+	err := v.waitUntilOrTimeoutAfter(i2c, RESULT_INTERRUPT_STATUS, v.measurementTimeout(),
+		func(checkReg byte, err error) (bool, error) {
+			if err != nil {
+				return false, err
+			}
+			return v.isNewResultReady(i2c, checkReg)
+		})
+	return v.recoverIfTimedOut(i2c, err)
+}
+
+// Check interrupt status register value for new measurement result.
+// Result is new, if interrupt was cleared after previous result taken,
+// or observed cleared since then. Otherwise (clear failed, or result
+// might be left pending by previous measurements) interrupt is cleared
+// again, to avoid reading the same result twice.
+func (v *Vl53l0x) isNewResultReady(i2c *i2c.I2C, interruptStatus byte) (bool, error) {
+	if !v.isDataReady(interruptStatus) {
+		v.resultArmed = true
+		return false, nil
+	} else if v.resultArmed {
+		return true, nil
+	}
+	v.debug("Interrupt is not re-asserted, clear it again")
+	return false, v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+}
+
+// Clear interrupt once measurement result is taken. Interrupt is cleared
+// by the time next measurement completes, so next "ready" state means new
+// result; unless clear failed, then its transition to "ready" is tracked.
+func (v *Vl53l0x) clearResultInterrupt(i2c *i2c.I2C) error {
+	v.resultArmed = false
+	err := v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
 	if err != nil {
 		return err
 	}
-	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, sequenceConfig)
-	if err != nil {
+	v.resultArmed = true
+	return nil
+}
+
+// SetRecoverOnTimeout set whether sensor is returned to idle state, when
+// measurement times out: measurement in progress (either single-shot,
+// or continuous) is stopped, and pending interrupt is cleared, before
+// timeout error is returned. Otherwise hung measurement might cause
+// subsequent measurements to fail until Reset. Continuous mode
+// should be started again after recovery.
+func (v *Vl53l0x) SetRecoverOnTimeout(enable bool) {
+	v.recoverOnTimeout = enable
+}
+
+// Return sensor to idle state, if err is a measurement timeout
+// and recovery is enabled. Returns err unchanged.
+func (v *Vl53l0x) recoverIfTimedOut(i2c *i2c.I2C, err error) error {
+	if err == nil || !v.recoverOnTimeout || !errors.Is(err, ErrTimeout) {
 		return err
 	}
 
-	// VL53L0X_perform_phase_calibration() end
+	v.debug("Measurement timed out, return sensor to idle state")
 
-	return nil
+	var err2 error
+	if v.continuous {
+		err2 = v.StopContinuous(i2c)
+	} else {
+		// writing start bit again would trigger new single-shot measurement
+		err2 = v.writeRegU8(i2c, SYSRANGE_START, 0x00) // VL53L0X_REG_SYSRANGE_MODE_SINGLESHOT
+		if err2 == nil {
+			v.stopVariableWritten = false
+		}
+	}
+	if err2 == nil {
+		err2 = v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+	}
+	if err2 != nil {
+		v.warningf("Failed to recover from measurement timeout: %v", err2)
+	}
+	return err
 }
 
-// Get the VCSEL pulse period in PCLKs for the given period type.
-// Based on VL53L0X_get_vcsel_pulse_period().
-func (v *Vl53l0x) getVcselPulsePeriod(i2c *i2c.I2C, tpe VcselPeriodType) (byte, error) {
+// ErrNoValidReading is returned by ReadRangeSingleValid, when no measurement
+// with valid range status is taken within retries allowed.
+var ErrNoValidReading = errors.New("no valid reading")
 
-	lg.Debug("Start getting VCSEL pulse period")
+// ReadRangeSingleValid performs single-shot range measurements, until
+// measurement is valid (device reports "range valid" status, and distance
+// is within measurable range), or maxRetries retries are exhausted,
+// in which case ErrNoValidReading is returned. Intended to overcome
+// transient failures, like sigma or signal fail.
+func (v *Vl53l0x) ReadRangeSingleValid(i2c *i2c.I2C, maxRetries int) (uint16, error) {
 
-	switch tpe {
-	case VcselPeriodPreRange:
-		u8, err := v.readRegU8(i2c, PRE_RANGE_CONFIG_VCSEL_PERIOD)
+	v.debugf("Read range single valid with %d retries", maxRetries)
+
+	for retry := 0; retry <= maxRetries; retry++ {
+		data, err := v.ReadRangeData(i2c)
 		if err != nil {
 			return 0, err
 		}
-		return v.decodeVcselPeriod(u8), nil
-	case VcselPeriodFinalRange:
-		u8, err := v.readRegU8(i2c, FINAL_RANGE_CONFIG_VCSEL_PERIOD)
-		if err != nil {
-			return 0, err
+		if v.isRangeValid(data) {
+			return data.RangeMm, nil
+		}
+		if retry < maxRetries {
+			v.debugf("Invalid reading (range status 0x%x), retry %d of %d",
+				data.RangeStatus, retry+1, maxRetries)
 		}
-		return v.decodeVcselPeriod(u8), nil
-	default:
-		return 0, errors.New("invalid VCSEL period type specified")
 	}
+	return 0, ErrNoValidReading
 }
 
-// StartContinuous start continuous ranging measurements. If period_ms (optional) is 0 or not
-// given, continuous back-to-back mode is used (the sensor takes measurements as
-// often as possible); otherwise, continuous timed mode is used, with the given
-// inter-measurement period in milliseconds determining how often the sensor
-// takes a measurement. Based on VL53L0X_StartMeasurement().
-func (v *Vl53l0x) StartContinuous(i2c *i2c.I2C, periodMs uint32) error {
+// ReadRangeAdaptive performs a single-shot range measurement like
+// ReadRangeSingleMillimeters does, but when measurement fails because
+// of weak return signal, signal rate limit is temporary halved and measurement
+// is repeated, until limit reach minLimitMcps (which should be positive),
+// or can't be lowered anymore in register format (1/128 MCPS steps).
+// Original signal rate limit is restored on exit. Returns true in second
+// value, if measurement was taken with lowered limit.
+func (v *Vl53l0x) ReadRangeAdaptive(i2c *i2c.I2C, minLimitMcps float32) (rng uint16, adapted bool, err error) {
 
-	lg.Debug("Start continuous")
+	v.debug("Read range adaptive")
 
-	err := v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x80, Value: 0x01},
-		{Reg: 0xFF, Value: 0x01},
-		{Reg: 0x00, Value: 0x00},
-		{Reg: 0x91, Value: v.stopVariable},
-		{Reg: 0x00, Value: 0x01},
-		{Reg: 0xFF, Value: 0x00},
-		{Reg: 0x80, Value: 0x00},
-	}...)
-	if err != nil {
-		return err
+	if minLimitMcps <= 0 {
+		return 0, false, errors.New(spew.Sprintf("minimum signal rate limit %v MCPS "+
+			"should be positive", minLimitMcps))
 	}
-	if periodMs != 0 {
-		// continuous timed mode
 
-		// VL53L0X_SetInterMeasurementPeriodMilliSeconds() begin
+	err = v.startSingleRange(i2c)
+	if err != nil {
+		return 0, false, err
+	}
+	data, err := v.readRange(i2c)
+	if err != nil {
+		return 0, false, err
+	}
+	if !v.isSignalFail(data.RangeMm, data.RangeStatus) {
+		return data.RangeMm, false, nil
+	}
 
-		oscCalibrateVal, err := v.readRegU16(i2c, OSC_CALIBRATE_VAL)
-		if err != nil {
-			return err
+	limit, err := v.GetSignalRateLimit(i2c)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		err2 := v.SetSignalRateLimit(i2c, limit)
+		if err == nil {
+			err = err2
 		}
+	}()
 
-		if oscCalibrateVal != 0 {
-			periodMs *= uint32(oscCalibrateVal)
+	prevFixed := MCPSToFixed(limit)
+	for lowered := limit / 2; lowered >= minLimitMcps; lowered /= 2 {
+		fixed := MCPSToFixed(lowered)
+		if fixed == prevFixed {
+			// register value doesn't change anymore
+			break
 		}
-
-		err = v.writeRegU32(i2c, SYSTEM_INTERMEASUREMENT_PERIOD, periodMs)
+		prevFixed = fixed
+		v.debugf("Weak signal, lower signal rate limit to %v MCPS", lowered)
+		err = v.SetSignalRateLimit(i2c, lowered)
 		if err != nil {
-			return err
+			return 0, false, err
 		}
-
-		// VL53L0X_SetInterMeasurementPeriodMilliSeconds() end
-
-		err = v.writeRegU8(i2c, SYSRANGE_START, 0x04) // VL53L0X_REG_SYSRANGE_MODE_TIMED
+		err = v.startSingleRange(i2c)
 		if err != nil {
-			return err
+			return 0, false, err
 		}
-	} else {
-		// continuous back-to-back mode
-		err = v.writeRegU8(i2c, SYSRANGE_START, 0x02) // VL53L0X_REG_SYSRANGE_MODE_BACKTOBACK
+		data, err = v.readRange(i2c)
 		if err != nil {
-			return err
+			return 0, false, err
+		}
+		if !v.isSignalFail(data.RangeMm, data.RangeStatus) {
+			return data.RangeMm, true, nil
 		}
 	}
-	return nil
-}
-
-// StopContinuous stop continuous measurements.
-// Based on VL53L0X_StopMeasurement().
-func (v *Vl53l0x) StopContinuous(i2c *i2c.I2C) error {
-
-	lg.Debug("Stop continuous")
-
-	err := v.writeRegValues(i2c, []RegBytePair{
-		{Reg: SYSRANGE_START, Value: 0x01}, // VL53L0X_REG_SYSRANGE_MODE_SINGLESHOT
-		{Reg: 0xFF, Value: 0x01},
-		{Reg: 0x00, Value: 0x00},
-		{Reg: 0x91, Value: 0x00},
-		{Reg: 0x00, Value: 0x01},
-		{Reg: 0xFF, Value: 0x00},
-	}...)
-	return err
+	return data.RangeMm, false, nil
 }
 
-// Read measured distance from the sensor.
-func (v *Vl53l0x) readRangeMillimeters(i2c *i2c.I2C) (uint16, error) {
+// ReadRangeSingleWithSignalLimit performs a single-shot range measurement
+// like ReadRangeSingleMillimeters does, with signal rate limit temporary
+// set to limitMcps. Original signal rate limit is restored on exit,
+// even if measurement failed.
+func (v *Vl53l0x) ReadRangeSingleWithSignalLimit(i2c *i2c.I2C, limitMcps float32) (rng uint16, err error) {
 
-	err := v.waitUntilOrTimeout(i2c, RESULT_INTERRUPT_STATUS,
-		func(checkReg byte, err error) (bool, error) {
-			return checkReg&0x07 != 0, err
-		})
-	if err != nil {
-		return 0, err
-	}
+	v.debugf("Read range single with signal rate limit %v MCPS", limitMcps)
 
-	// assumptions: Linearity Corrective Gain is 1000 (default);
-	// fractional ranging is not enabled
-	rng, err := v.readRegU16(i2c, RESULT_RANGE_STATUS+10)
-	if err != nil {
-		return 0, err
-	}
-	err = v.writeRegU8(i2c, SYSTEM_INTERRUPT_CLEAR, 0x01)
+	limit, err := v.GetSignalRateLimit(i2c)
 	if err != nil {
 		return 0, err
 	}
-
-	return rng, nil
-}
-
-// ReadRangeContinuousMillimeters returns a range reading in millimeters
-// when continuous mode is active (readRangeSingleMillimeters() also calls
-// this function after starting a single-shot range measurement).
-func (v *Vl53l0x) ReadRangeContinuousMillimeters(i2c *i2c.I2C) (uint16, error) {
-
-	lg.Debug("Read range continuous")
-
-	return v.readRangeMillimeters(i2c)
-}
-
-// ReadRangeSingleMillimeters performs a single-shot range measurement and returns the reading in
-// millimeters based on VL53L0X_PerformSingleRangingMeasurement().
-func (v *Vl53l0x) ReadRangeSingleMillimeters(i2c *i2c.I2C) (uint16, error) {
-
-	lg.Debug("Read range single")
-
-	err := v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x80, Value: 0x01},
-		{Reg: 0xFF, Value: 0x01},
-		{Reg: 0x00, Value: 0x00},
-		{Reg: 0x91, Value: v.stopVariable},
-		{Reg: 0x00, Value: 0x01},
-		{Reg: 0xFF, Value: 0x00},
-		{Reg: 0x80, Value: 0x00},
-		{Reg: SYSRANGE_START, Value: 0x01},
-	}...)
+	err = v.SetSignalRateLimit(i2c, limitMcps)
 	if err != nil {
 		return 0, err
 	}
+	defer func() {
+		err2 := v.SetSignalRateLimit(i2c, limit)
+		if err == nil {
+			err = err2
+		}
+	}()
 
-	// "Wait until start bit has been cleared"
-	err = v.waitUntilOrTimeout(i2c, SYSRANGE_START,
-		func(checkReg byte, err error) (bool, error) {
-			return checkReg&0x01 == 0, err
-		})
+	err = v.startSingleRange(i2c)
 	if err != nil {
 		return 0, err
 	}
@@ -1253,7 +2861,7 @@ func (v *Vl53l0x) encodeTimeout(timeoutMclks uint16) uint16 {
 // intermediate values.
 func (v *Vl53l0x) getSequenceStepTimeouts(i2c *i2c.I2C, enables SequenceStepEnables) (*SequenceStepTimeouts, error) {
 
-	lg.Debug("Start getting sequence step timeouts")
+	v.debug("Start getting sequence step timeouts")
 
 	timeouts := &SequenceStepTimeouts{}
 
@@ -1311,51 +2919,32 @@ func (v *Vl53l0x) getSequenceStepTimeouts(i2c *i2c.I2C, enables SequenceStepEnab
 // sqrt(N). Defaults to about 33 milliseconds; the minimum is 20 ms.
 // Based on VL53L0X_set_measurement_timing_budget_micro_seconds().
 func (v *Vl53l0x) SetMeasurementTimingBudget(i2c *i2c.I2C, budgetUsec uint32) error {
-	const StartOverhead = 1320 // note that this is different than the value in get_
-	const EndOverhead = 960
-	const MsrcOverhead = 660
-	const TccOverhead = 590
-	const DssOverhead = 690
-	const PreRangeOverhead = 660
-	const FinalRangeOverhead = 550
-
 	const MinTimingBudget = 20000
 
-	lg.Debug("Start setting measurement timing budget")
+	v.debug("Start setting measurement timing budget")
 
 	if budgetUsec < MinTimingBudget {
-		return errors.New("budget is lower than minimum allowed")
+		if !v.allowSubMinimumBudget {
+			return errors.New("budget is lower than minimum allowed")
+		}
+		v.warningf("Budget %d us is lower than minimum %d us, measurement accuracy degrades",
+			budgetUsec, MinTimingBudget)
 	}
-	var usedBudgetUsec uint32 = StartOverhead + EndOverhead
 
 	enables, err := v.getSequenceStepEnables(i2c)
 	if err != nil {
 		return err
 	}
-	lg.Debugf("Sequence step enables = %#v", enables)
+	v.debugf("Sequence step enables = %#v", enables)
 	timeouts, err := v.getSequenceStepTimeouts(i2c, *enables)
 	if err != nil {
 		return err
 	}
-	lg.Debugf("Sequence step timeouts = %#v", timeouts)
-
-	if enables.TCC {
-		usedBudgetUsec += timeouts.MsrcDssTccUsec + TccOverhead
-	}
-
-	if enables.DSS {
-		usedBudgetUsec += 2 * (timeouts.MsrcDssTccUsec + DssOverhead)
-	} else if enables.MSRC {
-		usedBudgetUsec += timeouts.MsrcDssTccUsec + MsrcOverhead
-	}
+	v.debugf("Sequence step timeouts = %#v", timeouts)
 
-	if enables.PreRange {
-		usedBudgetUsec += timeouts.PreRangeUsec + PreRangeOverhead
-	}
+	usedBudgetUsec := v.usedBudget(*enables, *timeouts)
 
 	if enables.FinalRange {
-		usedBudgetUsec += FinalRangeOverhead
-
 		// "Note that the final range timeout is determined by the timing
 		// budget and the sum of all other timeouts within the sequence.
 		// If there is no room for the final range timeout, then an error
@@ -1377,37 +2966,212 @@ func (v *Vl53l0x) SetMeasurementTimingBudget(i2c *i2c.I2C, budgetUsec uint32) er
 		//  timeouts must be expressed in macro periods MClks
 		//  because they have different vcsel periods."
 
-		lg.Debug("set_sequence_step_timeout() begin")
+		v.debug("set_sequence_step_timeout() begin")
+
+		finalRangeTimeoutMclks := v.timeoutMicrosecondsToMclks(finalRangeTimeoutUsec,
+			timeouts.FinalRangeVcselPeriodPclks)
+
+		if enables.PreRange {
+			finalRangeTimeoutMclks += uint32(timeouts.PreRangeMclks)
+		}
+
+		err = v.writeRegU16(i2c, FINAL_RANGE_CONFIG_TIMEOUT_MACROP_HI,
+			v.encodeTimeout(uint16(finalRangeTimeoutMclks)))
+		if err != nil {
+			return err
+		}
+
+		v.debug("set_sequence_step_timeout() end")
+
+		// set_sequence_step_timeout() end
+
+		v.measurementTimingBudgetUsec = budgetUsec // store for internal reuse
+		v.finalRangeTimeoutUsec = finalRangeTimeoutUsec
+		v.preRangeTimeoutUsec = 0
+		if enables.PreRange {
+			v.preRangeTimeoutUsec = timeouts.PreRangeUsec
+		}
+		v.preRangeVcselPclks = timeouts.PreRangeVcselPeriodPclks
+		v.finalRangeVcselPclks = timeouts.FinalRangeVcselPeriodPclks
+	}
+
+	v.debug("End setting measurement timing budget")
+
+	return nil
+}
+
+// Calculate part of timing budget in microseconds, used by all sequence steps
+// except final range one, including final range overhead, if step is enabled.
+// Based on VL53L0X_set_measurement_timing_budget_micro_seconds().
+func (v *Vl53l0x) usedBudget(enables SequenceStepEnables, timeouts SequenceStepTimeouts) uint32 {
+	const StartOverhead = 1320 // note that this is different than the value in get_
+	const EndOverhead = 960
+	const MsrcOverhead = 660
+	const TccOverhead = 590
+	const DssOverhead = 690
+	const PreRangeOverhead = 660
+	const FinalRangeOverhead = 550
+
+	var usedBudgetUsec uint32 = StartOverhead + EndOverhead
+
+	if enables.TCC {
+		usedBudgetUsec += timeouts.MsrcDssTccUsec + TccOverhead
+	}
+
+	if enables.DSS {
+		usedBudgetUsec += 2 * (timeouts.MsrcDssTccUsec + DssOverhead)
+	} else if enables.MSRC {
+		usedBudgetUsec += timeouts.MsrcDssTccUsec + MsrcOverhead
+	}
+
+	if enables.PreRange {
+		usedBudgetUsec += timeouts.PreRangeUsec + PreRangeOverhead
+	}
+
+	if enables.FinalRange {
+		usedBudgetUsec += FinalRangeOverhead
+	}
+	return usedBudgetUsec
+}
+
+// FinalRangeTimeout returns final range step timeout in microseconds,
+// chosen by last SetMeasurementTimingBudget call. It's a remainder of budget
+// left after all other sequence steps; the closer it to zero, the closer
+// budget to "requested timeout too big" error.
+func (v *Vl53l0x) FinalRangeTimeout() uint32 {
+	return v.finalRangeTimeoutUsec
+}
+
+// SetFinalRangeTimeout set final range step timeout in microseconds
+// directly, instead of letting SetMeasurementTimingBudget to assign
+// the remainder of budget. Measurement timing budget is recalculated
+// from the new step timeouts (see QueryTimingBudget).
+// Based on set_sequence_step_timeout() (VL53L0X_SEQUENCESTEP_FINAL_RANGE).
+func (v *Vl53l0x) SetFinalRangeTimeout(i2c *i2c.I2C, usec uint32) error {
+
+	v.debugf("Set final range timeout to %d us", usec)
+
+	enables, err := v.getSequenceStepEnables(i2c)
+	if err != nil {
+		return err
+	}
+	timeouts, err := v.getSequenceStepTimeouts(i2c, *enables)
+	if err != nil {
+		return err
+	}
+	// "For the final range timeout, the pre-range timeout
+	//  must be added."
+	finalRangeTimeoutMclks := v.timeoutMicrosecondsToMclks(usec,
+		timeouts.FinalRangeVcselPeriodPclks)
+	if enables.PreRange {
+		finalRangeTimeoutMclks += uint32(timeouts.PreRangeMclks)
+	}
+	if finalRangeTimeoutMclks > math.MaxUint16 {
+		return errors.New(spew.Sprintf("final range timeout %d us is too long", usec))
+	}
+	err = v.writeRegU16(i2c, FINAL_RANGE_CONFIG_TIMEOUT_MACROP_HI,
+		v.encodeTimeout(uint16(finalRangeTimeoutMclks)))
+	if err != nil {
+		return err
+	}
+	return v.refreshSequenceTimings(i2c)
+}
 
-		finalRangeTimeoutMclks := v.timeoutMicrosecondsToMclks(finalRangeTimeoutUsec,
-			timeouts.FinalRangeVcselPeriodPclks)
+// SetPreRangeTimeout set pre-range step timeout in microseconds directly.
+// Final range step timeout is kept unchanged, and measurement timing budget
+// is recalculated from the new step timeouts (see QueryTimingBudget).
+// Based on set_sequence_step_timeout() (VL53L0X_SEQUENCESTEP_PRE_RANGE).
+func (v *Vl53l0x) SetPreRangeTimeout(i2c *i2c.I2C, usec uint32) error {
 
-		if enables.PreRange {
-			finalRangeTimeoutMclks += uint32(timeouts.PreRangeMclks)
-		}
+	v.debugf("Set pre-range timeout to %d us", usec)
 
-		err = v.writeRegU16(i2c, FINAL_RANGE_CONFIG_TIMEOUT_MACROP_HI,
-			v.encodeTimeout(uint16(finalRangeTimeoutMclks)))
-		if err != nil {
-			return err
+	enables, err := v.getSequenceStepEnables(i2c)
+	if err != nil {
+		return err
+	}
+	timeouts, err := v.getSequenceStepTimeouts(i2c, *enables)
+	if err != nil {
+		return err
+	}
+	preRangeTimeoutMclks := v.timeoutMicrosecondsToMclks(usec,
+		timeouts.PreRangeVcselPeriodPclks)
+	if preRangeTimeoutMclks > math.MaxUint16 {
+		return errors.New(spew.Sprintf("pre-range timeout %d us is too long", usec))
+	}
+	// final range timeout register includes pre-range timeout,
+	// when pre-range step is enabled, so it should be updated as well
+	finalRangeTimeoutMclks := uint32(timeouts.FinalRangeMclks)
+	if enables.PreRange {
+		finalRangeTimeoutMclks += preRangeTimeoutMclks
+		if finalRangeTimeoutMclks > math.MaxUint16 {
+			return errors.New(spew.Sprintf("pre-range timeout %d us is too long", usec))
 		}
-
-		lg.Debug("set_sequence_step_timeout() end")
-
-		// set_sequence_step_timeout() end
-
-		v.measurementTimingBudgetUsec = budgetUsec // store for internal reuse
 	}
+	err = v.writeRegU16(i2c, PRE_RANGE_CONFIG_TIMEOUT_MACROP_HI,
+		v.encodeTimeout(uint16(preRangeTimeoutMclks)))
+	if err != nil {
+		return err
+	}
+	err = v.writeRegU16(i2c, FINAL_RANGE_CONFIG_TIMEOUT_MACROP_HI,
+		v.encodeTimeout(uint16(finalRangeTimeoutMclks)))
+	if err != nil {
+		return err
+	}
+	return v.refreshSequenceTimings(i2c)
+}
 
-	lg.Debug("End setting measurement timing budget")
-
+// Read sequence step timings and measurement timing budget
+// from the sensor, and store them for internal reuse.
+func (v *Vl53l0x) refreshSequenceTimings(i2c *i2c.I2C) error {
+	enables, err := v.getSequenceStepEnables(i2c)
+	if err != nil {
+		return err
+	}
+	timeouts, err := v.getSequenceStepTimeouts(i2c, *enables)
+	if err != nil {
+		return err
+	}
+	_, err = v.getMeasurementTimingBudget(i2c)
+	if err != nil {
+		return err
+	}
+	v.finalRangeTimeoutUsec = timeouts.FinalRangeUsec
+	v.preRangeTimeoutUsec = 0
+	if enables.PreRange {
+		v.preRangeTimeoutUsec = timeouts.PreRangeUsec
+	}
+	v.preRangeVcselPclks = timeouts.PreRangeVcselPeriodPclks
+	v.finalRangeVcselPclks = timeouts.FinalRangeVcselPeriodPclks
 	return nil
 }
 
+// AllowSubMinimumBudget relax SetMeasurementTimingBudget check to accept
+// budget lower than 20 ms minimum, at the cost of degraded accuracy.
+// Intended for experiments only; disabled by default.
+func (v *Vl53l0x) AllowSubMinimumBudget(allow bool) {
+	v.allowSubMinimumBudget = allow
+}
+
 // Get the measurement timing budget in microseconds
 // based on VL53L0X_get_measurement_timing_budget_micro_seconds()
 // in us (microseconds).
 func (v *Vl53l0x) getMeasurementTimingBudget(i2c *i2c.I2C) (uint32, error) {
+	budgetUsec, err := v.QueryTimingBudget(i2c)
+	if err != nil {
+		return 0, err
+	}
+
+	v.measurementTimingBudgetUsec = budgetUsec // store for internal reuse
+
+	return budgetUsec, nil
+}
+
+// QueryTimingBudget calculates the measurement timing budget in microseconds
+// from actual sensor registers. Unlike internal getter, it doesn't
+// update budget value stored for reuse, so it's safe to call
+// for diagnostic purpose at any time.
+// Based on VL53L0X_get_measurement_timing_budget_micro_seconds().
+func (v *Vl53l0x) QueryTimingBudget(i2c *i2c.I2C) (uint32, error) {
 	const StartOverhead = 1910 // note that this is different than the value in set_
 	const EndOverhead = 960
 	const MsrcOverhead = 660
@@ -1445,8 +3209,6 @@ func (v *Vl53l0x) getMeasurementTimingBudget(i2c *i2c.I2C) (uint32, error) {
 		budgetUsec += timeouts.FinalRangeUsec + FinalRangeOverhead
 	}
 
-	v.measurementTimingBudgetUsec = budgetUsec // store for internal reuse
-
 	return budgetUsec, nil
 }
 
@@ -1457,110 +3219,270 @@ type SpadInfo struct {
 	TypeIsAperture bool
 }
 
+// Reference SPAD array is architectural constant, not reported by the sensor.
+const (
+	// RefSpadArraySize is a number of SPADs in reference array
+	// (bits of GLOBAL_CONFIG_SPAD_ENABLES_REF_0..5 registers).
+	RefSpadArraySize = 48
+	// MaxRefSpadCount is a maximum number of reference SPADs,
+	// which could be enabled (SpadInfo.Count never exceeds it).
+	MaxRefSpadCount = 44
+)
+
+// GetSpadInfo returns reference SPAD count and type, which Init enables
+// (compare SpadInfo.Count with MaxRefSpadCount to assess detector).
+// Value read once is cached until Reset or ForceSpadInfoRefresh.
+func (v *Vl53l0x) GetSpadInfo(i2c *i2c.I2C) (*SpadInfo, error) {
+	return v.getSpadInfo(i2c)
+}
+
+// ForceSpadInfoRefresh drop reference SPAD info cached by Init,
+// so it's read from the sensor once again on next Init.
+func (v *Vl53l0x) ForceSpadInfoRefresh() {
+	v.spadInfo = nil
+}
+
 // Get reference SPAD (single photon avalanche diode) count and type
 // based on VL53L0X_get_info_from_device(),
 // but only gets reference SPAD count and type.
+// SPAD info is read once, then cached until Reset or ForceSpadInfoRefresh.
 func (v *Vl53l0x) getSpadInfo(i2c *i2c.I2C) (*SpadInfo, error) {
-	var tmp uint8
+	if v.spadInfo != nil {
+		v.debug("Use cached SPAD info")
+		si := *v.spadInfo
+		return &si, nil
+	}
+	var si *SpadInfo
+	err := v.withPowerForce(i2c, func() error {
+		return v.withPage(i2c, 0x01, func() error {
+			var err error
+			si, err = v.readSpadInfo(i2c)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	cached := *si
+	v.spadInfo = &cached
+	return si, nil
+}
 
-	err := v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x80, Value: 0x01},
-		{Reg: 0xFF, Value: 0x01},
-		{Reg: 0x00, Value: 0x00},
-	}...)
+// Read reference SPAD count and type, when access
+// to internal registers is enabled by getSpadInfo().
+func (v *Vl53l0x) readSpadInfo(i2c *i2c.I2C) (*SpadInfo, error) {
+	var si *SpadInfo
+	err := v.withNvmAccess(i2c, func() error {
+		err := v.nvmReadStrobe(i2c, 0x6b)
+		if errors.Is(err, ErrTimeout) {
+			return fmt.Errorf("SPAD info read timed out: %w", err)
+		} else if err != nil {
+			return err
+		}
+		tmp, err := v.readRegU8(i2c, 0x92)
+		if err != nil {
+			return err
+		}
+		si = &SpadInfo{Count: tmp & 0x7F, TypeIsAperture: (tmp>>7)&0x01 != 0}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return si, nil
+}
+
+// GetUniqueID read part UID from sensor NVM (non-volatile memory).
+// UID is programmed in factory, so it doesn't depend on I2C-bus address
+// and could be used as sensor serial number.
+// Based on VL53L0X_get_info_from_device().
+func (v *Vl53l0x) GetUniqueID(i2c *i2c.I2C) (uint64, error) {
+
+	v.debug("Get unique ID")
+
+	var upper, lower uint32
+	err := v.withPowerForce(i2c, func() error {
+		return v.withPage(i2c, 0x01, func() error {
+			return v.withNvmAccess(i2c, func() error {
+				err := v.nvmReadStrobe(i2c, 0x7B)
+				if err != nil {
+					return err
+				}
+				upper, err = v.readRegU32(i2c, 0x90)
+				if err != nil {
+					return err
+				}
+				err = v.nvmReadStrobe(i2c, 0x7C)
+				if err != nil {
+					return err
+				}
+				lower, err = v.readRegU32(i2c, 0x90)
+				return err
+			})
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return uint64(upper)<<32 | uint64(lower), nil
+}
 
+// Enable NVM (non-volatile memory) read access, run fn, then disable access,
+// even if fn failed. Should be called with register page 0x01 selected.
+func (v *Vl53l0x) withNvmAccess(i2c *i2c.I2C, fn func() error) (err error) {
 	err = v.writeRegU8(i2c, 0xFF, 0x06)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	u8, err := v.readRegU8(i2c, 0x83)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	err = v.writeRegValues(i2c, []RegBytePair{
 		{Reg: 0x83, Value: u8 | 0x04},
 		{Reg: 0xFF, Value: 0x07},
 		{Reg: 0x81, Value: 0x01},
+		{Reg: 0x80, Value: 0x01},
 	}...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	err = v.writeRegU8(i2c, 0x80, 0x01)
-	if err != nil {
-		return nil, err
-	}
+	defer func() {
+		err2 := v.writeRegValues(i2c, []RegBytePair{
+			{Reg: 0x81, Value: 0x00},
+			{Reg: 0xFF, Value: 0x06},
+		}...)
+		if err2 == nil {
+			u8, err2 = v.readRegU8(i2c, 0x83)
+		}
+		if err2 == nil {
+			err2 = v.writeRegValues(i2c, []RegBytePair{
+				{Reg: 0x83, Value: u8 & ^byte(0x04)},
+				{Reg: 0xFF, Value: 0x01},
+			}...)
+		}
+		if err == nil {
+			err = err2
+		} else if err2 != nil {
+			v.errorf("Failed to disable NVM access: %v", err2)
+		}
+	}()
 
-	err = v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x94, Value: 0x6b},
+	return fn()
+}
+
+// Request NVM data specified by cmd and wait until it's available
+// in registers starting from 0x90.
+// Based on VL53L0X_device_read_strobe().
+func (v *Vl53l0x) nvmReadStrobe(i2c *i2c.I2C, cmd byte) error {
+	err := v.writeRegValues(i2c, []RegBytePair{
+		{Reg: 0x94, Value: cmd},
 		{Reg: 0x83, Value: 0x00},
 	}...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	err = v.waitUntilOrTimeout(i2c, 0x83,
 		func(checkReg byte, err error) (bool, error) {
 			return checkReg != 0, err
 		})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	err = v.writeRegU8(i2c, 0x83, 0x01)
+	return v.writeRegU8(i2c, 0x83, 0x01)
+}
+
+// SetCalibrationTimeout set time to wait for completion of each reference
+// calibration step (VHV and phase calibration), performed by Init,
+// PerformRefCalibration and VCSEL pulse period change.
+// Zero value means default I/O timeout.
+func (v *Vl53l0x) SetCalibrationTimeout(timeout time.Duration) {
+	v.calibrationTimeout = timeout
+}
+
+// PerformRefCalibration perform reference calibration: VHV (very high voltage)
+// calibration followed by phase calibration. Returned error tells which one
+// timed out (see SetCalibrationTimeout), if any.
+// Based on VL53L0X_PerformRefCalibration().
+func (v *Vl53l0x) PerformRefCalibration(i2c *i2c.I2C) error {
+
+	v.debug("Perform reference calibration")
+
+	sequenceConfig, err := v.readRegU8(i2c, SYSTEM_SEQUENCE_CONFIG)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	tmp, err = v.readRegU8(i2c, 0x92)
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, 0x01)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	si := &SpadInfo{Count: tmp & 0x7F, TypeIsAperture: (tmp>>7)&0x01 != 0}
-
-	err = v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x81, Value: 0x00},
-		{Reg: 0xFF, Value: 0x06},
-	}...)
+	err = v.performSingleRefCalibration(i2c, 0x40)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	u8, err = v.readRegU8(i2c, 0x83)
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, 0x02)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	err = v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0x83, Value: u8 & ^byte(0x04)},
-		{Reg: 0xFF, Value: 0x01},
-		{Reg: 0x00, Value: 0x01},
-	}...)
+	err = v.performSingleRefCalibration(i2c, 0x00)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	err = v.writeRegValues(i2c, []RegBytePair{
-		{Reg: 0xFF, Value: 0x00},
-		{Reg: 0x80, Value: 0x00},
-	}...)
+	err = v.writeRegU8(i2c, SYSTEM_SEQUENCE_CONFIG, sequenceConfig)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	v.refCalibratedAt = time.Now()
+	return nil
+}
 
-	return si, nil
+// Default time after which reference calibration is considered outdated.
+const defaultRecalibrationInterval = time.Minute * 30
+
+// SetRecalibrationInterval set time after last reference calibration
+// (done by Init or PerformRefCalibration), when NeedsRecalibration starts
+// to report that calibration is outdated. Defaults to 30 minutes.
+// Zero value means that calibration never gets outdated.
+func (v *Vl53l0x) SetRecalibrationInterval(interval time.Duration) {
+	v.recalibrationInterval = interval
+}
+
+// NeedsRecalibration returns true, if reference calibration should be
+// repeated by PerformRefCalibration. ST recommends to repeat it, when
+// temperature changes by more than 8 degrees Celsius, but sensor neither
+// measures temperature, nor has a flag for that, so heuristic is used:
+// calibration is outdated, when recalibration interval is passed since
+// the last one (see SetRecalibrationInterval), or it was never done.
+func (v *Vl53l0x) NeedsRecalibration() bool {
+	if v.refCalibratedAt.IsZero() {
+		return true
+	}
+	return v.recalibrationInterval > 0 &&
+		time.Since(v.refCalibratedAt) > v.recalibrationInterval
 }
 
 // Based on VL53L0X_perform_single_ref_calibration().
+// Perform VHV calibration if vhvInitByte is 0x40, otherwise phase calibration.
 func (v *Vl53l0x) performSingleRefCalibration(i2c *i2c.I2C, vhvInitByte uint8) error {
 	err := v.writeRegU8(i2c, SYSRANGE_START, 0x01|vhvInitByte) // VL53L0X_REG_SYSRANGE_MODE_START_STOP
 	if err != nil {
 		return err
 	}
-	err = v.waitUntilOrTimeout(i2c, RESULT_INTERRUPT_STATUS,
+	timeout := v.calibrationTimeout
+	if timeout == 0 {
+		timeout = v.ioTimeout
+	}
+	err = v.waitUntilOrTimeoutAfter(i2c, RESULT_INTERRUPT_STATUS, timeout,
 		func(checkReg byte, err error) (bool, error) {
-			return checkReg&0x07 != 0, err
+			return v.isDataReady(checkReg), err
 		})
-	if err != nil {
+	if errors.Is(err, ErrTimeout) {
+		if vhvInitByte == 0x40 {
+			return fmt.Errorf("VHV calibration timed out: %w", err)
+		}
+		return fmt.Errorf("phase calibration timed out: %w", err)
+	} else if err != nil {
 		return err
 	}
 	err = v.writeRegValues(i2c, []RegBytePair{
@@ -1584,10 +3506,61 @@ func (v *Vl53l0x) startTimeout() time.Time {
 	return time.Now()
 }
 
+// AutoTimeout set whether timeout of waiting for measurement result is
+// derived from measurement timing budget (or inter-measurement period
+// in continuous timed mode, if it's longer), instead of fixed 1 second
+// timeout: timeout is 5 times budget plus 50 ms margin. So it adapts
+// to accuracy mode: short for HighSpeed, long enough for HighestAccuracy.
+// Disabled by default.
+func (v *Vl53l0x) AutoTimeout(enable bool) {
+	v.autoTimeout = enable
+}
+
+// Returns timeout of waiting for measurement result.
+func (v *Vl53l0x) measurementTimeout() time.Duration {
+	const BudgetMultiplier = 5
+	const Margin = time.Millisecond * 50
+	if !v.autoTimeout || v.measurementTimingBudgetUsec == 0 {
+		return v.ioTimeout
+	}
+	period := time.Duration(v.measurementTimingBudgetUsec) * time.Microsecond
+	if v.continuous {
+		if p := time.Duration(v.continuousPeriodMs) * time.Millisecond; p > period {
+			period = p
+		}
+	}
+	return period*BudgetMultiplier + Margin
+}
+
 // Raise timeout event if execution time exceed value in Vl53l0x.ioTimeout.
 func (v *Vl53l0x) checkTimeoutExpired(startTime time.Time) bool {
+	return v.checkTimeoutExpiredAfter(startTime, v.ioTimeout)
+}
+
+// Raise timeout event if execution time exceed timeout value.
+func (v *Vl53l0x) checkTimeoutExpiredAfter(startTime time.Time, timeout time.Duration) bool {
 	left := time.Now().Sub(startTime)
-	return v.ioTimeout > 0 && left > v.ioTimeout
+	return timeout > 0 && left > timeout
+}
+
+// ErrTimeout is matched by errors.Is for any error caused
+// by timeout of waiting for sensor event.
+var ErrTimeout = errors.New("timeout occurs")
+
+// Timeout error, which keeps last value of register polled.
+type timeoutError struct {
+	reg   byte
+	value byte
+}
+
+// Error implement error interface.
+func (v *timeoutError) Error() string {
+	return spew.Sprintf("timeout occurs; last read register 0x%x equal to 0x%x", v.reg, v.value)
+}
+
+// Is used by errors.Is to match ErrTimeout.
+func (v *timeoutError) Is(target error) bool {
+	return target == ErrTimeout
 }
 
 // Read specific register in the loop until condition is true,
@@ -1595,6 +3568,23 @@ func (v *Vl53l0x) checkTimeoutExpired(startTime time.Time) bool {
 func (v *Vl53l0x) waitUntilOrTimeout(i2c *i2c.I2C, reg byte,
 	breakWhen func(chechReg byte, err error) (bool, error)) error {
 
+	return v.waitUntilOrTimeoutAfter(i2c, reg, v.ioTimeout, breakWhen)
+}
+
+// Read specific register in the loop until condition is true,
+// or wait for timeout event, which occurs after timeout value.
+func (v *Vl53l0x) waitUntilOrTimeoutAfter(i2c *i2c.I2C, reg byte, timeout time.Duration,
+	breakWhen func(chechReg byte, err error) (bool, error)) error {
+
+	return v.waitUntilOrTimeoutEvery(i2c, reg, timeout, 0, breakWhen)
+}
+
+// Read specific register in the loop, sleeping interval between reads,
+// until condition is true, or wait for timeout event, which occurs
+// after timeout value.
+func (v *Vl53l0x) waitUntilOrTimeoutEvery(i2c *i2c.I2C, reg byte, timeout, interval time.Duration,
+	breakWhen func(chechReg byte, err error) (bool, error)) error {
+
 	st := v.startTimeout()
 	for {
 		u8, err := v.readRegU8(i2c, reg)
@@ -1604,38 +3594,95 @@ func (v *Vl53l0x) waitUntilOrTimeout(i2c *i2c.I2C, reg byte,
 		} else if f {
 			break
 		}
-		if v.checkTimeoutExpired(st) {
-			return errors.New(spew.Sprintf("timeout occurs; last read register 0x%x equal to 0x%x", reg, u8))
+		if v.checkTimeoutExpiredAfter(st, timeout) {
+			v.metrics.addTimeout()
+			return &timeoutError{reg: reg, value: u8}
+		}
+		if interval > 0 {
+			time.Sleep(interval)
 		}
 	}
 	return nil
 }
 
+// ReadReg8 reads an 8-bit register. Intended for experiments
+// with registers not covered by other methods.
+func (v *Vl53l0x) ReadReg8(i2c *i2c.I2C, reg byte) (byte, error) {
+	return v.readRegU8(i2c, reg)
+}
+
+// ReadReg16BE reads a 16-bit register (MSB first).
+func (v *Vl53l0x) ReadReg16BE(i2c *i2c.I2C, reg byte) (uint16, error) {
+	return v.readRegU16(i2c, reg)
+}
+
+// ReadReg32BE reads a 32-bit register (MSB first).
+func (v *Vl53l0x) ReadReg32BE(i2c *i2c.I2C, reg byte) (uint32, error) {
+	return v.readRegU32(i2c, reg)
+}
+
+// WriteReg8 writes an 8-bit register. Intended for experiments
+// with registers not covered by other methods.
+func (v *Vl53l0x) WriteReg8(i2c *i2c.I2C, reg byte, value byte) error {
+	return v.writeRegU8(i2c, reg, value)
+}
+
+// WriteReg16BE writes a 16-bit register (MSB first).
+func (v *Vl53l0x) WriteReg16BE(i2c *i2c.I2C, reg byte, value uint16) error {
+	return v.writeRegU16(i2c, reg, value)
+}
+
+// WriteReg32BE writes a 32-bit register (MSB first).
+func (v *Vl53l0x) WriteReg32BE(i2c *i2c.I2C, reg byte, value uint32) error {
+	return v.writeRegU32(i2c, reg, value)
+}
+
 // Write an 8-bit register.
 func (v *Vl53l0x) writeRegU8(i2c *i2c.I2C, reg byte, value uint8) error {
-	return i2c.WriteRegU8(reg, value)
+	if v.isClosed() {
+		return ErrClosed
+	}
+	err := i2c.WriteRegU8(reg, value)
+	v.metrics.addBusError(err)
+	return err
 }
 
 // Write a 16-bit register.
 func (v *Vl53l0x) writeRegU16(i2c *i2c.I2C, reg byte, value uint16) error {
+	if v.isClosed() {
+		return ErrClosed
+	}
 	buf := []byte{reg, byte(value >> 8 & 0xFF), byte(value & 0xFF)}
 	_, err := i2c.WriteBytes(buf)
+	v.metrics.addBusError(err)
 	return err
 }
 
 // Write a 32-bit register.
 func (v *Vl53l0x) writeRegU32(i2c *i2c.I2C, reg byte, value uint32) error {
-	buf := []byte{reg, byte(value >> 24 & 0xFF), byte(value >> 16 & 0xFF),
-		byte(value >> 8 & 0xFF), byte(value & 0xFF)}
-	_, err := i2c.WriteBytes(buf)
+	if v.isClosed() {
+		return ErrClosed
+	}
+	_, err := i2c.WriteBytes(encodeRegU32(reg, value))
+	v.metrics.addBusError(err)
 	return err
 }
 
+// Compose write transfer of a 32-bit register (MSB first).
+func encodeRegU32(reg byte, value uint32) []byte {
+	return []byte{reg, byte(value >> 24 & 0xFF), byte(value >> 16 & 0xFF),
+		byte(value >> 8 & 0xFF), byte(value & 0xFF)}
+}
+
 // Write an arbitrary number of bytes from the given array to the sensor,
 // starting at the given register.
 func (v *Vl53l0x) writeBytes(i2c *i2c.I2C, reg byte, buf []byte) error {
+	if v.isClosed() {
+		return ErrClosed
+	}
 	b := append([]byte{reg}, buf...)
 	_, err := i2c.WriteBytes(b)
+	v.metrics.addBusError(err)
 	return err
 }
 
@@ -1647,6 +3694,89 @@ type RegBytePair struct {
 	Value uint8
 }
 
+// Select register page and enable access to it, run fn,
+// then restore default register page, even if fn failed.
+func (v *Vl53l0x) withPage(i2c *i2c.I2C, page byte, fn func() error) (err error) {
+	defer func() {
+		restore := []RegBytePair{
+			{Reg: 0x00, Value: 0x01},
+			{Reg: 0xFF, Value: 0x00},
+		}
+		if err != nil {
+			// fn might leave another page selected
+			restore = append([]RegBytePair{{Reg: 0xFF, Value: page}}, restore...)
+		}
+		err2 := v.writeRegValues(i2c, restore...)
+		if err == nil {
+			err = err2
+		} else if err2 != nil {
+			v.errorf("Failed to restore register page: %v", err2)
+		}
+	}()
+	err = v.writeRegValues(i2c, []RegBytePair{
+		{Reg: 0xFF, Value: page},
+		{Reg: 0x00, Value: 0x00},
+	}...)
+	if err != nil {
+		return err
+	}
+	return fn()
+}
+
+// Force sensor power on, run fn, then release
+// power force, even if fn failed.
+func (v *Vl53l0x) withPowerForce(i2c *i2c.I2C, fn func() error) (err error) {
+	defer func() {
+		err2 := v.writeRegU8(i2c, POWER_MANAGEMENT_GO1_POWER_FORCE, 0x00)
+		if err == nil {
+			err = err2
+		} else if err2 != nil {
+			v.errorf("Failed to release power force: %v", err2)
+		}
+	}()
+	err = v.writeRegU8(i2c, POWER_MANAGEMENT_GO1_POWER_FORCE, 0x01)
+	if err != nil {
+		return err
+	}
+	return fn()
+}
+
+// WriteRegValues write bunch of registers with corresponding values, like
+// tuning settings from ST API. Intended for experiments with registers
+// not covered by other methods. Pairs are validated by ValidateRegValues
+// before any write, to not leave sensor with non-default register page
+// selected, which breaks all subsequent operations.
+func (v *Vl53l0x) WriteRegValues(i2c *i2c.I2C, pairs ...RegBytePair) error {
+	err := ValidateRegValues(pairs...)
+	if err != nil {
+		return err
+	}
+	return v.writeRegValues(i2c, pairs...)
+}
+
+// ValidateRegValues check that bunch of register writes restore
+// default state at the end: register page 0x00 is selected (register 0xFF),
+// and power force is released (register 0x80), if any of them is changed.
+func ValidateRegValues(pairs ...RegBytePair) error {
+	var page, powerForce byte
+	for _, pair := range pairs {
+		switch pair.Reg {
+		case 0xFF:
+			page = pair.Value
+		case POWER_MANAGEMENT_GO1_POWER_FORCE:
+			powerForce = pair.Value
+		}
+	}
+	if page != 0x00 {
+		return errors.New(spew.Sprintf("register page 0x%x is left selected, "+
+			"while default page 0x00 is expected", page))
+	}
+	if powerForce != 0x00 {
+		return errors.New("power force is left enabled")
+	}
+	return nil
+}
+
 // Write bunch of registers with with corresponding values.
 func (v *Vl53l0x) writeRegValues(i2c *i2c.I2C, pairs ...RegBytePair) error {
 	for _, pair := range pairs {
@@ -1660,19 +3790,61 @@ func (v *Vl53l0x) writeRegValues(i2c *i2c.I2C, pairs ...RegBytePair) error {
 
 // Read an 8-bit register.
 func (v *Vl53l0x) readRegU8(i2c *i2c.I2C, reg byte) (uint8, error) {
+	if v.isClosed() {
+		return 0, ErrClosed
+	}
 	u8, err := i2c.ReadRegU8(reg)
+	v.metrics.addBusError(err)
 	return u8, err
 }
 
+// ReadRetryPolicy define how register reads of read-modify-write
+// operations in Init are retried on transient errors.
+type ReadRetryPolicy struct {
+	// Number of retries after failed read. Zero value means no retries.
+	MaxRetries int
+	// Delay between retries.
+	Delay time.Duration
+}
+
+// SetReadRetryPolicy set how Init retries register reads of its
+// read-modify-write operations, which otherwise abort initialization
+// on single transient bus error. Fatal errors (device or bus is gone)
+// are never retried.
+func (v *Vl53l0x) SetReadRetryPolicy(policy ReadRetryPolicy) {
+	v.readRetryPolicy = policy
+}
+
+// Read an 8-bit register, retrying failed read according to read retry policy.
+func (v *Vl53l0x) readRegU8Retry(i2c *i2c.I2C, reg byte) (uint8, error) {
+	for retry := 0; ; retry++ {
+		u8, err := v.readRegU8(i2c, reg)
+		if err == nil || isFatalBusError(err) || errors.Is(err, ErrClosed) ||
+			retry >= v.readRetryPolicy.MaxRetries {
+			return u8, err
+		}
+		v.debugf("Read register 0x%x failed, retry %d of %d: %v",
+			reg, retry+1, v.readRetryPolicy.MaxRetries, err)
+		if v.readRetryPolicy.Delay > 0 {
+			time.Sleep(v.readRetryPolicy.Delay)
+		}
+	}
+}
+
 // Read a 16-bit register.
 func (v *Vl53l0x) readRegU16(i2c *i2c.I2C, reg byte) (uint16, error) {
+	if v.isClosed() {
+		return 0, ErrClosed
+	}
 	_, err := i2c.WriteBytes([]byte{reg})
 	if err != nil {
+		v.metrics.addBusError(err)
 		return 0, err
 	}
 	var buf [2]byte
 	_, err = i2c.ReadBytes(buf[0:])
 	if err != nil {
+		v.metrics.addBusError(err)
 		return 0, err
 	}
 	u16 := uint16(buf[0])<<8 | uint16(buf[1])
@@ -1681,13 +3853,18 @@ func (v *Vl53l0x) readRegU16(i2c *i2c.I2C, reg byte) (uint16, error) {
 
 // Read a 32-bit register.
 func (v *Vl53l0x) readRegU32(i2c *i2c.I2C, reg byte) (uint32, error) {
+	if v.isClosed() {
+		return 0, ErrClosed
+	}
 	_, err := i2c.WriteBytes([]byte{reg})
 	if err != nil {
+		v.metrics.addBusError(err)
 		return 0, err
 	}
 	var buf [4]byte
 	_, err = i2c.ReadBytes(buf[0:])
 	if err != nil {
+		v.metrics.addBusError(err)
 		return 0, err
 	}
 	u32 := uint32(buf[0])<<24 | uint32(buf[1])<<16 |
@@ -1698,10 +3875,15 @@ func (v *Vl53l0x) readRegU32(i2c *i2c.I2C, reg byte) (uint32, error) {
 // Read an arbitrary number of bytes from the sensor, starting at the given
 // register, into the given array.
 func (v *Vl53l0x) readRegBytes(i2c *i2c.I2C, reg byte, dest []byte) error {
+	if v.isClosed() {
+		return ErrClosed
+	}
 	_, err := i2c.WriteBytes([]byte{reg})
 	if err != nil {
+		v.metrics.addBusError(err)
 		return err
 	}
 	_, err = i2c.ReadBytes(dest)
+	v.metrics.addBusError(err)
 	return err
 }
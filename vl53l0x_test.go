@@ -0,0 +1,152 @@
+package vl53l0x
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"testing"
+
+	i2c "github.com/d2r2/go-i2c"
+)
+
+func TestMCPSToFixed(t *testing.T) {
+	const step = float32(1) / (1 << 7)
+	tests := []struct {
+		name string
+		mcps float32
+		want uint16
+	}{
+		{"zero", 0, 0},
+		{"negative", -1, 0},
+		{"small negative", -step / 4, 0},
+		{"below half step", float32(1)/256 - 1e-6, 0},
+		{"half step", float32(1) / 256, 1},
+		{"above half step", float32(1)/256 + 1e-6, 1},
+		{"one step", step, 1},
+		{"default signal rate limit", 0.25, 32},
+		{"one", 1, 128},
+		{"round down", 1 + step/2 - 1e-4, 128},
+		{"round up", 1 + step/2 + 1e-4, 129},
+		{"maximum", FixedToMCPS(math.MaxUint16), math.MaxUint16},
+		{"below maximum", 511.98, 65533},
+		{"rounded to maximum", 511.99, math.MaxUint16},
+		{"above maximum", 512, math.MaxUint16},
+		{"far above maximum", 1e6, math.MaxUint16},
+		{"infinity", float32(math.Inf(1)), math.MaxUint16},
+	}
+	for _, test := range tests {
+		if got := MCPSToFixed(test.mcps); got != test.want {
+			t.Errorf("%s: MCPSToFixed(%v) = %d, want %d", test.name, test.mcps, got, test.want)
+		}
+	}
+}
+
+func TestFixedToMCPS(t *testing.T) {
+	tests := []struct {
+		fixed uint16
+		want  float32
+	}{
+		{0, 0},
+		{1, float32(1) / 128},
+		{32, 0.25},
+		{128, 1},
+		{math.MaxUint16, 511.9921875},
+	}
+	for _, test := range tests {
+		if got := FixedToMCPS(test.fixed); got != test.want {
+			t.Errorf("FixedToMCPS(%d) = %v, want %v", test.fixed, got, test.want)
+		}
+	}
+}
+
+func TestFixedToMCPSRoundTrip(t *testing.T) {
+	for u := 0; u <= math.MaxUint16; u++ {
+		if got := MCPSToFixed(FixedToMCPS(uint16(u))); got != uint16(u) {
+			t.Fatalf("MCPSToFixed(FixedToMCPS(%d)) = %d", u, got)
+		}
+	}
+}
+
+func TestInterMeasurementPeriodTicks(t *testing.T) {
+	tests := []struct {
+		name            string
+		periodMs        uint32
+		oscCalibrateVal uint16
+		want            uint32
+		wantErr         bool
+	}{
+		{"not calibrated", 100, 0, 100, false},
+		// typical oscillator calibration value
+		{"100 ms", 100, 0x0BF0, 100 * 0x0BF0, false},
+		{"zero period", 0, 0x0BF0, 0, false},
+		{"longest period", math.MaxUint32 / 0x0BF0, 0x0BF0, math.MaxUint32 / 0x0BF0 * 0x0BF0, false},
+		{"overflow", math.MaxUint32/0x0BF0 + 1, 0x0BF0, 0, true},
+	}
+	for _, test := range tests {
+		got, err := interMeasurementPeriodTicks(test.periodMs, test.oscCalibrateVal)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		} else if got != test.want {
+			t.Errorf("%s: interMeasurementPeriodTicks(%d, %d) = %d, want %d",
+				test.name, test.periodMs, test.oscCalibrateVal, got, test.want)
+		}
+	}
+}
+
+func TestEncodeRegU32(t *testing.T) {
+	// 100 ms period with oscillator calibration value 0x0BF0
+	got := encodeRegU32(SYSTEM_INTERMEASUREMENT_PERIOD, 100*0x0BF0)
+	want := []byte{SYSTEM_INTERMEASUREMENT_PERIOD, 0x00, 0x04, 0xA9, 0xC0}
+	if string(got) != string(want) {
+		t.Errorf("encodeRegU32() = % x, want % x", got, want)
+	}
+}
+
+// Open connection to sensor at DefaultAddress on I2C-bus specified by
+// VL53L0X_TEST_BUS environment variable, and initialize it.
+// Benchmark is skipped, if variable isn't set.
+func openTestSensor(b *testing.B) (*Vl53l0x, *i2c.I2C) {
+	env := os.Getenv("VL53L0X_TEST_BUS")
+	if env == "" {
+		b.Skip("VL53L0X_TEST_BUS isn't set, sensor is required")
+	}
+	bus, err := strconv.Atoi(env)
+	if err != nil {
+		b.Fatalf("invalid VL53L0X_TEST_BUS: %v", err)
+	}
+	conn, err := i2c.NewI2C(DefaultAddress, bus)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+	sensor := NewVl53l0x()
+	err = sensor.Init(conn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return sensor, conn
+}
+
+// Compare with BenchmarkReadRangeSingleFast to see
+// stop variable preamble overhead.
+func BenchmarkReadRangeSingleMillimeters(b *testing.B) {
+	sensor, conn := openTestSensor(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := sensor.ReadRangeSingleMillimeters(conn)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadRangeSingleFast(b *testing.B) {
+	sensor, conn := openTestSensor(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := sensor.ReadRangeSingleFast(conn)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}